@@ -0,0 +1,51 @@
+package gobayeux
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// aclProbe is the data sent to, and expected back from, the ACL probe
+// channel for each channel about to be subscribed to
+type aclProbe struct {
+	Channel Channel `json:"channel"`
+}
+
+// probeACL asks the server over the configured probe channel whether each of
+// the channels may be subscribed to and returns an AuthorizationError for
+// each one it denies. Channels the server does not explicitly deny are
+// treated as permitted.
+func (b *BayeuxClient) probeACL(ctx context.Context, clientID string, channels []Channel) (map[Channel]error, error) {
+	ms := make([]Message, 0, len(channels))
+	for _, channel := range channels {
+		data, err := json.Marshal(aclProbe{channel})
+		if err != nil {
+			return nil, err
+		}
+		ms = append(ms, Message{Channel: b.aclProbe, ClientID: clientID, Data: data})
+	}
+
+	resp, err := b.request(ctx, ms)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := b.parseResponse(ctx, OperationSubscribe, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	denied := make(map[Channel]error)
+	for _, m := range response {
+		if m.Channel != b.aclProbe || m.Successful {
+			continue
+		}
+
+		var probe aclProbe
+		if err := json.Unmarshal(m.Data, &probe); err != nil {
+			return nil, err
+		}
+		denied[probe.Channel] = AuthorizationError{Channel: probe.Channel, Message: m.Error}
+	}
+	return denied, nil
+}
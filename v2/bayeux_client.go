@@ -2,18 +2,30 @@ package gobayeux
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/publicsuffix"
 )
 
+// DefaultUserAgent is the User-Agent sent with every request unless one is
+// provided with WithUserAgent
+const DefaultUserAgent = "gobayeux/2"
+
+// defaultVersion is the protocol version sent in the handshake unless one is
+// provided with WithBayeuxVersion
+const defaultVersion = "1.0"
+
 // BayeuxClient is a way of acting as a client with a given Bayeux server
 type BayeuxClient struct {
 	stateMachine  *ConnectionStateMachine
@@ -21,11 +33,45 @@ type BayeuxClient struct {
 	serverAddress *url.URL
 	state         *clientState
 	exts          []MessageExtender
+	extsLock      sync.RWMutex
 	logger        Logger
+	codec         *codecNegotiation
+	userAgent     string
+	observer      RequestObserver
+	metrics       Metrics
+	aclProbe      Channel
+	timeouts      *operationTimeouts
+	handshakeExt  map[string]interface{}
+	version       string
+	minVersion    string
+	encoding      HTTPEncoding
+	// connType is either long-polling or callback-polling
+	connType string
+	maxBytes int64
+	// interceptRequest, when set, may replace every batch before it is sent
+	interceptRequest MessageInterceptor
+	// interceptResponse, when set, may replace every batch received
+	interceptResponse MessageInterceptor
+	logPayloads       bool
+	redactPayload     PayloadRedactor
+	// lastID is the id of the most recent message sent
+	lastID uint64
 }
 
-// NewBayeuxClient initializes a BayeuxClient for the user
-func NewBayeuxClient(client *http.Client, transport http.RoundTripper, serverAddress string, logger Logger) (*BayeuxClient, error) {
+// NewBayeuxClient initializes a BayeuxClient for the user. Any opts which
+// configure the HTTP client, transport, or logger are ignored in favour of
+// the explicit arguments.
+//
+// serverAddress must be an http or https URL. A ws or wss URL is converted to
+// http or https respectively and any other scheme returns a BadSchemeError.
+func NewBayeuxClient(client *http.Client, transport http.RoundTripper, serverAddress string, logger Logger, opts ...Option) (*BayeuxClient, error) {
+	options := &Options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+
 	if client == nil {
 		jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 		if err != nil {
@@ -38,32 +84,124 @@ func NewBayeuxClient(client *http.Client, transport http.RoundTripper, serverAdd
 			Jar:           jar,
 			Timeout:       http.DefaultClient.Timeout,
 		}
+	} else {
+		// Work on a copy so the caller's client is left as it was given
+		c := *client
+		client = &c
+	}
+	// Only an explicit transport replaces the one the client already has
+	if transport != nil {
+		client.Transport = transport
+	}
+	if options.TLSConfig != nil {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		var err error
+		if client.Transport, err = withTLSConfig(transport, options.TLSConfig); err != nil {
+			return nil, err
+		}
 	}
-	if transport == nil {
-		transport = http.DefaultTransport
+	if options.tunesTransport() {
+		transport := client.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		var err error
+		if client.Transport, err = withTransportTuning(transport, options); err != nil {
+			return nil, err
+		}
 	}
-	client.Transport = transport
 
 	parsedAddress, err := url.Parse(serverAddress)
 	if err != nil {
 		return nil, err
 	}
+	// Only long-polling over HTTP is supported so a websocket address is
+	// taken to mean the same server over HTTP
+	switch parsedAddress.Scheme {
+	case "http", "https":
+	case "ws":
+		parsedAddress.Scheme = "http"
+	case "wss":
+		parsedAddress.Scheme = "https"
+	default:
+		return nil, BadSchemeError{parsedAddress.Scheme}
+	}
 
 	if logger == nil {
 		logger = newNullLogger()
 	}
 
+	if options.Codec == nil {
+		options.Codec = jsonCodec{}
+	}
+
+	if options.Observer == nil {
+		options.Observer = nullObserver{}
+	}
+
+	if options.Metrics == nil {
+		options.Metrics = nullMetrics{}
+	}
+
+	if options.UserAgent == "" {
+		options.UserAgent = DefaultUserAgent
+	}
+
+	if options.Version == "" {
+		options.Version = defaultVersion
+	}
+	if err := validateVersion(options.Version); err != nil {
+		return nil, err
+	}
+	if options.MinimumVersion == "" {
+		options.MinimumVersion = options.Version
+	}
+	if err := validateVersion(options.MinimumVersion); err != nil {
+		return nil, err
+	}
+
+	if options.ACLProbe != "" && options.ACLProbe.Type() != ServiceChannel {
+		return nil, InvalidChannelError{options.ACLProbe}
+	}
+
+	connectionType := ConnectionTypeLongPolling
+	if options.CallbackPolling {
+		connectionType = ConnectionTypeCallbackPolling
+	}
+
 	return &BayeuxClient{
-		stateMachine:  NewConnectionStateMachine(),
-		client:        client,
-		serverAddress: parsedAddress,
-		state:         &clientState{},
-		logger:        logger,
+		stateMachine:      NewConnectionStateMachine(),
+		client:            client,
+		serverAddress:     parsedAddress,
+		state:             &clientState{},
+		logger:            logger,
+		codec:             newCodecNegotiation(options.Codec, options.NegotiatedCodecs),
+		userAgent:         options.UserAgent,
+		observer:          options.Observer,
+		metrics:           options.Metrics,
+		aclProbe:          options.ACLProbe,
+		timeouts:          &operationTimeouts{meta: options.MetaTimeout, handshake: options.HandshakeTimeout, connect: options.ConnectTimeout},
+		handshakeExt:      options.HandshakeExt,
+		version:           options.Version,
+		minVersion:        options.MinimumVersion,
+		encoding:          options.HTTPEncoding,
+		connType:          connectionType,
+		maxBytes:          options.MaxResponseBytes,
+		interceptRequest:  options.RequestInterceptor,
+		interceptResponse: options.ResponseInterceptor,
+		logPayloads:       options.PayloadLogging,
+		redactPayload:     options.PayloadRedactor,
 	}, nil
 }
 
 // Handshake sends the handshake request to the Bayeux Server
-func (b *BayeuxClient) Handshake(ctx context.Context) ([]Message, error) {
+func (b *BayeuxClient) Handshake(ctx context.Context) (_ []Message, err error) {
+	ctx, finish := b.startRequest(ctx, OperationHandshake)
+	defer func() { finish(err) }()
+
 	logger := b.logger.WithField("at", "handshake")
 	start := time.Now()
 	logger.Debug("starting")
@@ -72,12 +210,18 @@ func (b *BayeuxClient) Handshake(ctx context.Context) ([]Message, error) {
 		return nil, HandshakeFailedError{err}
 	}
 	builder := NewHandshakeRequestBuilder()
-	if err := builder.AddVersion("1.0"); err != nil {
+	if err := builder.AddVersion(b.version); err != nil {
+		return nil, HandshakeFailedError{err}
+	}
+	if err := builder.AddMinimumVersion(b.minVersion); err != nil {
 		return nil, HandshakeFailedError{err}
 	}
-	if err := builder.AddSupportedConnectionType("long-polling"); err != nil {
+	if err := builder.AddSupportedConnectionType(b.connType); err != nil {
 		return nil, HandshakeFailedError{err}
 	}
+	for key, value := range b.handshakeExt {
+		builder.AddExt(key, value)
+	}
 	ms, err := builder.Build()
 	if err != nil {
 		return nil, HandshakeFailedError{err}
@@ -88,20 +232,22 @@ func (b *BayeuxClient) Handshake(ctx context.Context) ([]Message, error) {
 		return nil, HandshakeFailedError{err}
 	}
 
-	response, err := b.parseResponse(resp)
+	response, err := b.parseResponse(ctx, OperationHandshake, resp)
 	if err != nil {
 		logger.WithError(err).Debug("error parsing response")
 		return response, HandshakeFailedError{err}
 	}
-	if len(response) > 1 {
-		return response, HandshakeFailedError{ErrTooManyMessages}
-	}
-
+	// Some servers send events and advice alongside the handshake reply,
+	// which are returned with it, but there must be only one reply
 	var message Message
 	for _, m := range response {
-		if m.Channel == MetaHandshake {
-			message = m
+		if m.Channel != MetaHandshake {
+			continue
 		}
+		if message.Channel != emptyChannel {
+			return response, HandshakeFailedError{ErrTooManyMessages}
+		}
+		message = m
 	}
 	if message.Channel == emptyChannel {
 		return response, HandshakeFailedError{ErrBadChannel}
@@ -109,16 +255,43 @@ func (b *BayeuxClient) Handshake(ctx context.Context) ([]Message, error) {
 	if !message.Successful {
 		return response, newHandshakeError(message.Error)
 	}
-	b.state.SetClientID(message.ClientID)
+	if !supportsConnectionType(message.SupportedConnectionTypes, b.connType) {
+		logger.WithField("supported", message.SupportedConnectionTypes).
+			WithField("connectionType", b.connType).
+			Debug("server does not support the connection type")
+		return response, HandshakeFailedError{UnsupportedConnectionTypeError{
+			ConnectionType: b.connType,
+			Supported:      message.SupportedConnectionTypes,
+		}}
+	}
+	b.state.SetSession(newSessionInfo(message, b.connType))
 	_ = b.stateMachine.ProcessEvent(successfullyConnected)
-	logger.WithField("duration", time.Since(start)).Debug("finishing")
+	logger.WithField("clientID", message.ClientID).WithField("duration", time.Since(start)).Debug("finishing")
 	return response, nil
 }
 
+// supportsConnectionType reports whether connectionType is among those the
+// server listed in its handshake response. Servers which leave the list out
+// are assumed to support it.
+func supportsConnectionType(supported []string, connectionType string) bool {
+	if len(supported) == 0 {
+		return true
+	}
+	for _, ct := range supported {
+		if ct == connectionType {
+			return true
+		}
+	}
+	return false
+}
+
 // Connect sends the connect request to the Bayeux Server. The specification
 // says that clients MUST maintain only one outstanding connect request. See
 // https://docs.cometd.org/current/reference/#_bayeux_meta_connect
-func (b *BayeuxClient) Connect(ctx context.Context) ([]Message, error) {
+func (b *BayeuxClient) Connect(ctx context.Context) (_ []Message, err error) {
+	ctx, finish := b.startRequest(ctx, OperationConnect)
+	defer func() { finish(err) }()
+
 	logger := b.logger.WithField("at", "connect")
 	start := time.Now()
 	logger.Debug("starting")
@@ -126,28 +299,70 @@ func (b *BayeuxClient) Connect(ctx context.Context) ([]Message, error) {
 	if !b.stateMachine.IsConnected() || clientID == "" {
 		return nil, ErrClientNotConnected
 	}
+	logger = logger.WithField("clientID", clientID)
 	builder := NewConnectRequestBuilder()
 	builder.AddClientID(clientID)
-	_ = builder.AddConnectionType(ConnectionTypeLongPolling)
+	_ = builder.AddConnectionType(b.connType)
 	ms, err := builder.Build()
 	if err != nil {
 		return nil, ConnectionFailedError{err}
 	}
+	// Only one /meta/connect may be outstanding so a response to any but
+	// the latest is dropped
+	id := b.nextMessageID()
+	ms[0].ID = id
+	b.state.StartConnect(id)
+	logger = logger.WithField("id", id)
 
 	resp, err := b.request(ctx, ms)
 	if err != nil {
 		logger.WithError(err).Debug("error during request")
 		return nil, ConnectionFailedError{err}
 	}
+	if b.discardStale(resp, clientID) {
+		logger.Debug("dropping response for replaced session")
+		return nil, ConnectionFailedError{ErrStaleSession}
+	}
 
-	response, err := b.parseResponse(resp)
+	// Advice is only taken from a reply to the latest /meta/connect
+	response, err := b.decodeResponse(ctx, OperationConnect, resp)
 	if err != nil {
 		logger.WithError(err).Debug("error parsing response")
 		return response, ConnectionFailedError{err}
 	}
 
+	// Events in the response still belong to this session so only the
+	// stale /meta/connect reply itself is dropped
+	latest := b.state.IsLatestConnect(id)
+	stale := false
+	replied := false
+	kept := response[:0]
 	for _, m := range response {
-		if m.Channel == MetaConnect && !m.Successful {
+		if m.Channel != MetaConnect {
+			kept = append(kept, m)
+			continue
+		}
+		// Servers echo the id; one that does not match answers another
+		// request
+		if !latest || (m.ID != "" && m.ID != id) {
+			logger.WithField("replyID", m.ID).Debug("dropping out of order /meta/connect response")
+			stale = true
+			continue
+		}
+		replied = true
+		kept = append(kept, m)
+	}
+	response = kept
+	if !latest || (stale && !replied) {
+		return response, ConnectionFailedError{ErrStaleConnect}
+	}
+	b.observeAdvice(response)
+
+	for _, m := range response {
+		if m.Channel != MetaConnect {
+			continue
+		}
+		if !m.Successful {
 			return response, ConnectionFailedError{ErrFailedToConnect}
 		}
 	}
@@ -157,54 +372,108 @@ func (b *BayeuxClient) Connect(ctx context.Context) ([]Message, error) {
 
 // Subscribe issues a MetaSubscribe request to the server to subscribe to the
 // channels in the subscriptions slice
-func (b *BayeuxClient) Subscribe(ctx context.Context, subscriptions []Channel) ([]Message, error) {
+func (b *BayeuxClient) Subscribe(ctx context.Context, subscriptions []Channel) (_ []Message, err error) {
+	ctx, finish := b.startRequest(ctx, OperationSubscribe)
+	defer func() { finish(err) }()
+
 	logger := b.logger.WithField("at", "subscribe")
 	start := time.Now()
 	logger.Debug("starting")
 	clientID := b.state.GetClientID()
 	if !b.stateMachine.IsConnected() || clientID == "" {
 		logger.Debug("cannot subscribe because client is not connected")
-		return nil, SubscriptionFailedError{subscriptions, ErrClientNotConnected}
+		return nil, SubscriptionFailedError{Channels: subscriptions, Err: ErrClientNotConnected}
+	}
+	logger = logger.WithField("clientID", clientID)
+
+	permitted := subscriptions
+	var denied map[Channel]error
+	if b.aclProbe != "" {
+		denied, err = b.probeACL(ctx, clientID, subscriptions)
+		if err != nil {
+			return nil, SubscriptionFailedError{Channels: subscriptions, Err: err}
+		}
+		if len(denied) > 0 {
+			permitted = make([]Channel, 0, len(subscriptions))
+			for _, s := range subscriptions {
+				if _, ok := denied[s]; !ok {
+					permitted = append(permitted, s)
+				}
+			}
+		}
+	}
+
+	var failure SubscriptionFailedError
+	for _, s := range subscriptions {
+		if err, ok := denied[s]; ok {
+			if failure.Failed == nil {
+				failure = SubscriptionFailedError{
+					Channels: subscriptions,
+					Err:      err,
+					Failed:   make(map[Channel]error),
+				}
+			}
+			failure.Failed[s] = err
+		}
+	}
+	if len(permitted) == 0 && failure.Failed != nil {
+		logger.Debug("server denied every subscription")
+		return nil, failure
 	}
 
 	builder := NewSubscribeRequestBuilder()
 	builder.AddClientID(clientID)
-	for _, s := range subscriptions {
+	for _, s := range permitted {
 		if err := builder.AddSubscription(s); err != nil {
-			return nil, SubscriptionFailedError{subscriptions, err}
+			return nil, SubscriptionFailedError{Channels: subscriptions, Err: err}
 		}
 	}
 
 	ms, err := builder.Build()
 	if err != nil {
-		return nil, SubscriptionFailedError{subscriptions, err}
+		return nil, SubscriptionFailedError{Channels: subscriptions, Err: err}
 	}
 
 	resp, err := b.request(ctx, ms)
 	if err != nil {
-		return nil, SubscriptionFailedError{subscriptions, err}
+		return nil, SubscriptionFailedError{Channels: subscriptions, Err: err}
+	}
+	if b.discardStale(resp, clientID) {
+		logger.Debug("dropping response for replaced session")
+		return nil, SubscriptionFailedError{Channels: subscriptions, Err: ErrStaleSession}
 	}
 
-	response, err := b.parseResponse(resp)
+	response, err := b.parseResponse(ctx, OperationSubscribe, resp)
 	if err != nil {
-		return nil, SubscriptionFailedError{subscriptions, err}
+		return nil, SubscriptionFailedError{Channels: subscriptions, Err: err}
 	}
 
 	for _, m := range response {
 		if m.Channel == MetaSubscribe && !m.Successful {
-			return nil, SubscriptionFailedError{
-				Channels: subscriptions,
-				Err:      newSubscribeError(m.Error),
+			if failure.Failed == nil {
+				failure = SubscriptionFailedError{
+					Channels: subscriptions,
+					Err:      newSubscribeError(m.Error),
+					Failed:   make(map[Channel]error),
+				}
 			}
+			failure.Failed[m.Subscription] = newSubscribeError(m.Error)
 		}
 	}
+	if failure.Failed != nil {
+		logger.WithField("failed", len(failure.Failed)).Debug("server rejected subscriptions")
+		return response, failure
+	}
 	logger.WithField("duration", time.Since(start)).Debug("finishing")
 	return response, nil
 }
 
 // Unsubscribe issues a MetaUnsubscribe request to the server to subscribe to the
 // channels in the subscriptions slice
-func (b *BayeuxClient) Unsubscribe(ctx context.Context, subscriptions []Channel) ([]Message, error) {
+func (b *BayeuxClient) Unsubscribe(ctx context.Context, subscriptions []Channel) (_ []Message, err error) {
+	ctx, finish := b.startRequest(ctx, OperationUnsubscribe)
+	defer func() { finish(err) }()
+
 	clientID := b.state.GetClientID()
 	if !b.stateMachine.IsConnected() || clientID == "" {
 		return nil, UnsubscribeFailedError{subscriptions, ErrClientNotConnected}
@@ -227,8 +496,11 @@ func (b *BayeuxClient) Unsubscribe(ctx context.Context, subscriptions []Channel)
 	if err != nil {
 		return nil, UnsubscribeFailedError{subscriptions, err}
 	}
+	if b.discardStale(resp, clientID) {
+		return nil, UnsubscribeFailedError{subscriptions, ErrStaleSession}
+	}
 
-	response, err := b.parseResponse(resp)
+	response, err := b.parseResponse(ctx, OperationUnsubscribe, resp)
 	if err != nil {
 		return response, UnsubscribeFailedError{subscriptions, err}
 	}
@@ -244,9 +516,60 @@ func (b *BayeuxClient) Unsubscribe(ctx context.Context, subscriptions []Channel)
 	return response, nil
 }
 
+// Publish sends data to the server on channel and returns once the server
+// has replied. A reply which is not successful returns a PublishFailedError.
+//
+// See also: https://docs.cometd.org/current/reference/#_publish
+func (b *BayeuxClient) Publish(ctx context.Context, channel Channel, data json.RawMessage) (_ []Message, err error) {
+	ctx, finish := b.startRequest(ctx, OperationPublish)
+	defer func() { finish(err) }()
+
+	logger := b.logger.WithField("at", "publish").WithField("channel", channel)
+	clientID := b.state.GetClientID()
+	if !b.stateMachine.IsConnected() || clientID == "" {
+		return nil, PublishFailedError{channel, ErrClientNotConnected}
+	}
+	if !channel.IsValid() || channel.HasWildcard() || channel.Type() == MetaChannel {
+		return nil, PublishFailedError{channel, InvalidChannelError{channel}}
+	}
+
+	ms := []Message{{Channel: channel, ClientID: clientID, Data: data}}
+	resp, err := b.request(ctx, ms)
+	if err != nil {
+		return nil, PublishFailedError{channel, err}
+	}
+	if b.discardStale(resp, clientID) {
+		logger.Debug("dropping response for replaced session")
+		return nil, PublishFailedError{channel, ErrStaleSession}
+	}
+
+	response, err := b.parseResponse(ctx, OperationPublish, resp)
+	if err != nil {
+		return response, PublishFailedError{channel, err}
+	}
+
+	// The server may deliver other messages along with its reply, which
+	// echoes the id of the published message. Echoing it is optional so a
+	// reply without an id counts too, but only events carry data.
+	for _, m := range response {
+		echoed := m.ID == ms[0].ID || (m.ID == "" && m.Data == nil)
+		if m.Channel != channel || !echoed {
+			continue
+		}
+		if !m.Successful {
+			return response, PublishFailedError{channel, newPublishError(m.Error)}
+		}
+		return response, nil
+	}
+	return response, PublishFailedError{channel, ErrNoPublishReply}
+}
+
 // Disconnect sends a /meta/disconnect request to the Bayeux server to
 // terminate the session
-func (b *BayeuxClient) Disconnect(ctx context.Context) ([]Message, error) {
+func (b *BayeuxClient) Disconnect(ctx context.Context) (_ []Message, err error) {
+	ctx, finish := b.startRequest(ctx, OperationDisconnect)
+	defer func() { finish(err) }()
+
 	clientID := b.state.GetClientID()
 	if !b.stateMachine.IsConnected() || clientID == "" {
 		return nil, DisconnectFailedError{ErrClientNotConnected}
@@ -264,7 +587,7 @@ func (b *BayeuxClient) Disconnect(ctx context.Context) ([]Message, error) {
 		return nil, DisconnectFailedError{err}
 	}
 
-	response, err := b.parseResponse(resp)
+	response, err := b.parseResponse(ctx, OperationDisconnect, resp)
 	if err != nil {
 		return response, DisconnectFailedError{err}
 	}
@@ -280,63 +603,270 @@ func (b *BayeuxClient) Disconnect(ctx context.Context) ([]Message, error) {
 // UseExtension adds the provided MessageExtender to the list of known
 // extensions
 func (b *BayeuxClient) UseExtension(ext MessageExtender) error {
+	b.extsLock.Lock()
+	defer b.extsLock.Unlock()
+
 	for _, registered := range b.exts {
 		if ext == registered {
 			return AlreadyRegisteredError{ext}
 		}
 	}
-	b.exts = append(b.exts, ext)
+	// Requests in flight hold on to the previous slice so it must not be
+	// appended to in place
+	exts := make([]MessageExtender, len(b.exts), len(b.exts)+1)
+	copy(exts, b.exts)
+	b.exts = append(exts, ext)
 	return nil
 }
 
-func (b *BayeuxClient) request(ctx context.Context, ms []Message) (*http.Response, error) {
-	for _, ext := range b.exts {
-		for _, m := range ms {
-			ext.Outgoing(&m)
+// RemoveExtension removes the provided MessageExtender from the list of
+// known extensions and calls its Unregistered method. Requests already in
+// flight may still run it.
+func (b *BayeuxClient) RemoveExtension(ext MessageExtender) error {
+	b.extsLock.Lock()
+	defer b.extsLock.Unlock()
+
+	for i, registered := range b.exts {
+		if ext != registered {
+			continue
 		}
+		exts := make([]MessageExtender, 0, len(b.exts)-1)
+		exts = append(exts, b.exts[:i]...)
+		b.exts = append(exts, b.exts[i+1:]...)
+		ext.Unregistered()
+		return nil
 	}
+	return NotRegisteredError{ext}
+}
+
+// extensions returns the registered extensions. The slice returned is never
+// modified, so it is safe to range over while extensions are being added.
+func (b *BayeuxClient) extensions() []MessageExtender {
+	b.extsLock.RLock()
+	defer b.extsLock.RUnlock()
+	return b.exts
+}
+
+// startRequest applies the timeout for an operation of the given kind and
+// notifies the RequestObserver and Metrics that it is starting. It returns
+// the function to call with its result
+func (b *BayeuxClient) startRequest(ctx context.Context, kind string) (context.Context, func(error)) {
+	switch kind {
+	case OperationHandshake:
+		b.metrics.IncHandshake()
+	case OperationConnect:
+		b.metrics.IncConnect()
+	case OperationSubscribe:
+		b.metrics.IncSubscribe()
+	case OperationUnsubscribe:
+		b.metrics.IncUnsubscribe()
+	case OperationPublish:
+		b.metrics.IncPublish()
+	case OperationDisconnect:
+		b.metrics.IncDisconnect()
+	}
+
+	start := time.Now()
+	ctx, cancel := b.timeouts.withTimeout(ctx, kind)
+	ctx = withRequestInfo(ctx, RequestInfo{Operation: kind, ClientID: b.state.GetClientID()})
+	ctx, finish := b.observer.StartRequest(ctx, kind)
+	return ctx, func(err error) {
+		cancel()
+		b.metrics.ObserveLatency(kind, time.Since(start))
+		if err != nil {
+			b.metrics.IncError(kind)
+		}
+		finish(err)
+	}
+}
+
+// nextMessageID returns a new id for a message
+func (b *BayeuxClient) nextMessageID() string {
+	return strconv.FormatUint(atomic.AddUint64(&b.lastID, 1), 10)
+}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(ms); err != nil {
+func (b *BayeuxClient) request(ctx context.Context, ms []Message) (*http.Response, error) {
+	// The server echoes each id in its reply so every message gets a new one
+	for i := range ms {
+		if ms[i].ID == "" {
+			ms[i].ID = b.nextMessageID()
+		}
+	}
+	if err := extendOutgoing(ctx, b.extensions(), ms); err != nil {
 		return nil, err
 	}
+	if b.interceptRequest != nil {
+		var err error
+		if ms, err = b.interceptRequest(ctx, ms); err != nil {
+			return nil, err
+		}
+	}
+	b.logPayload(b.logger.WithField("at", "request"), "sending payload", ms)
 
-	req, err := http.NewRequestWithContext(ctx, "POST", b.serverAddress.String(), &buf)
+	codec, mediaType := b.codec.request()
+	body, err := codec.Marshal(ms)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
+
+	var req *http.Request
+	if b.connType == ConnectionTypeCallbackPolling {
+		req, err = newCallbackPollingRequest(ctx, b.serverAddress, body)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		body, mediaType = b.encoding.encode(body, mediaType)
+		req, err = http.NewRequestWithContext(ctx, "POST", b.serverAddress.String(), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", mediaType)
+	}
+	req.Header.Set("Accept", b.codec.accept)
+	req.Header.Set("User-Agent", b.userAgent)
 	return b.client.Do(req)
 }
 
-func (b *BayeuxClient) parseResponse(resp *http.Response) ([]Message, error) {
-	messages := make([]Message, 0)
+// discardStale closes resp and reports true if the client has re-handshaken
+// since the request was sent for clientID. Nothing in the response may be
+// applied to the new session, not even by extensions or advice.
+func (b *BayeuxClient) discardStale(resp *http.Response, clientID string) bool {
+	if b.state.GetClientID() == clientID {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+func (b *BayeuxClient) parseResponse(ctx context.Context, kind string, resp *http.Response) ([]Message, error) {
+	messages, err := b.decodeResponse(ctx, kind, resp)
+	if err != nil {
+		return nil, err
+	}
+	b.observeAdvice(messages)
+	return messages, nil
+}
+
+// observeAdvice merges the advice in messages into what the server has
+// advised so far
+func (b *BayeuxClient) observeAdvice(messages []Message) {
+	b.timeouts.observeAdvice(messages)
+	for _, m := range messages {
+		if m.Advice != nil {
+			b.state.ObserveAdvice(m.Advice)
+		}
+	}
+}
+
+// decodeResponse reads the messages in resp like parseResponse without
+// taking any notice of their advice
+func (b *BayeuxClient) decodeResponse(ctx context.Context, kind string, resp *http.Response) ([]Message, error) {
 	defer resp.Body.Close()
 
+	// Some servers end a long-poll that has nothing to deliver with a 204
+	if resp.StatusCode == http.StatusNoContent {
+		return []Message{}, nil
+	}
+
+	// http.Transport asks for and decompresses gzip by itself as we never
+	// set Accept-Encoding, other RoundTrippers may pass it through
+	var reader io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	if b.maxBytes > 0 {
+		// Read one byte more than allowed to tell whether there was more
+		reader = io.LimitReader(reader, b.maxBytes+1)
+	}
+
 	if resp.StatusCode != 200 {
-		body, err := io.ReadAll(resp.Body)
+		body, err := io.ReadAll(reader)
 		if err != nil {
 			b.logger.WithError(err).Debug("error reading body")
 		}
 
-		return nil, BadResponseError{resp.StatusCode, resp.Status, body}
+		return nil, BadResponseError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       body,
+			Operation:  kind,
+		}
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if b.maxBytes > 0 && int64(len(body)) > b.maxBytes {
+		return nil, ResponseTooLargeError{Limit: b.maxBytes, Operation: kind}
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+	if len(body) == 0 {
+		return []Message{}, nil
+	}
+	if b.connType == ConnectionTypeCallbackPolling {
+		if body, err = unwrapCallback(body); err != nil {
+			return nil, err
+		}
+	}
+
+	codec, isJSON := b.codec.response(resp.Header.Get("Content-Type"))
+
+	var messages []Message
+	if isJSON {
+		// Decode each message separately so that we can hold on to the raw
+		// bytes the server sent for it
+		var raws []json.RawMessage
+		if err := codec.Unmarshal(body, &raws); err != nil {
+			return nil, err
+		}
+
+		messages = make([]Message, len(raws))
+		for i, raw := range raws {
+			if err := codec.Unmarshal(raw, &messages[i]); err != nil {
+				return nil, err
+			}
+			messages[i].Raw = raw
+		}
+	} else if err := codec.Unmarshal(body, &messages); err != nil {
 		return nil, err
 	}
-	for _, ext := range b.exts {
-		for _, m := range messages {
-			ext.Incoming(&m)
+	b.logPayload(b.logger.WithField("at", kind), "received payload", messages)
+	if b.interceptResponse != nil {
+		if messages, err = b.interceptResponse(ctx, messages); err != nil {
+			return nil, err
 		}
 	}
+	if err := extendIncoming(ctx, b.extensions(), messages); err != nil {
+		return nil, err
+	}
+
+	logger := b.logger.WithField("at", kind)
+	for _, m := range messages {
+		logger.WithField("channel", m.Channel).
+			WithField("id", m.ID).
+			WithField("clientID", m.ClientID).
+			WithField("successful", m.Successful).
+			Debug("received message")
+	}
 	return messages, nil
 }
 
 type clientState struct {
 	clientID string
-	lock     sync.RWMutex
+	session  SessionInfo
+	// advice is the advice from every response merged in the order they
+	// were received
+	advice *Advice
+	// connectID is the id of the most recently sent /meta/connect
+	connectID string
+	lock      sync.RWMutex
 }
 
 func (cs *clientState) GetClientID() string {
@@ -345,8 +875,61 @@ func (cs *clientState) GetClientID() string {
 	return cs.clientID
 }
 
+// StartConnect records id as the most recently sent /meta/connect
+func (cs *clientState) StartConnect(id string) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.connectID = id
+}
+
+// IsLatestConnect reports whether no /meta/connect was sent after id
+func (cs *clientState) IsLatestConnect(id string) bool {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.connectID == id
+}
+
 func (cs *clientState) SetClientID(clientID string) {
 	cs.lock.Lock()
 	defer cs.lock.Unlock()
 	cs.clientID = clientID
 }
+
+func (cs *clientState) GetSession() SessionInfo {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.session.copy()
+}
+
+// SetSession replaces the session after a successful handshake. The advice
+// given so far carries over except for advice to handshake, which the
+// handshake has followed, and multiple-clients advice.
+func (cs *clientState) SetSession(session SessionInfo) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.clientID = session.ClientID
+	cs.session = session
+	if cs.advice.ShouldHandshake() {
+		cs.advice.Reconnect = ReconnectRetry
+	}
+	if cs.advice != nil {
+		// Only the old session was seen on multiple connections
+		cs.advice.MultipleClients = false
+	}
+	cs.session.observeAdvice(cs.advice)
+}
+
+// GetAdvice returns a copy of the advice currently in effect
+func (cs *clientState) GetAdvice() *Advice {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.advice.Merge(nil)
+}
+
+// ObserveAdvice merges advice into the advice currently in effect
+func (cs *clientState) ObserveAdvice(advice *Advice) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.advice = cs.advice.Merge(advice)
+	cs.session.observeAdvice(cs.advice)
+}
@@ -1,6 +1,17 @@
 package gobayeux
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func TestClientState_GetClientID(t *testing.T) {
 	want := "fakeClientID"
@@ -19,3 +30,456 @@ func TestClientState_SetClientID(t *testing.T) {
 		t.Errorf("error retrieving client ID; want %s got %s", want, got)
 	}
 }
+
+func TestConnectDropsResponseForReplacedSession(t *testing.T) {
+	var handshakes int32
+	connecting := make(chan struct{})
+	release := make(chan struct{})
+	transport := transportFn(func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			switch m.Channel {
+			case MetaHandshake:
+				n := atomic.AddInt32(&handshakes, 1)
+				replies = append(replies, Message{Channel: m.Channel, ID: m.ID, ClientID: fmt.Sprintf("client-%d", n), Successful: true})
+			case MetaConnect:
+				close(connecting)
+				<-release
+				replies = append(replies,
+					Message{Channel: m.Channel, ID: m.ID, ClientID: m.ClientID, Successful: true},
+					Message{Channel: "/foo/bar", Data: json.RawMessage(`"late"`)},
+				)
+			}
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	client, err := NewBayeuxClient(nil, transport, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	type result struct {
+		ms  []Message
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		ms, err := client.Connect(ctx)
+		done <- result{ms, err}
+	}()
+	<-connecting
+
+	// The server replaces the session while the connect is outstanding
+	_ = client.stateMachine.ProcessEvent(timeout)
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to re-handshake (%v)", err)
+	}
+	close(release)
+
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for connect")
+	}
+	if !errors.Is(r.err, ErrStaleSession) {
+		t.Errorf("expected ErrStaleSession, got %v", r.err)
+	}
+	if len(r.ms) != 0 {
+		t.Errorf("expected the late response to be dropped, got %v", r.ms)
+	}
+	if got := client.state.GetClientID(); got != "client-2" {
+		t.Errorf("expected the new session to be kept; want client-2 got %s", got)
+	}
+}
+
+func TestClientIgnoresResponsesForReplacedSession(t *testing.T) {
+	// The session is replaced while the first /meta/subscribe and the first
+	// /meta/connect are outstanding, as when a re-handshake races them
+	var client *Client
+	var sessions, subscribes, connects, subscribed int32
+	transport := transportFn(func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			reply := Message{Channel: m.Channel, ID: m.ID, ClientID: m.ClientID, Successful: true}
+			switch m.Channel {
+			case MetaHandshake:
+				reply.ClientID = "client-0"
+			case MetaSubscribe:
+				if atomic.AddInt32(&subscribes, 1) == 1 {
+					client.client.state.SetClientID(fmt.Sprintf("client-%d", atomic.AddInt32(&sessions, 1)))
+				} else {
+					atomic.StoreInt32(&subscribed, 1)
+				}
+			case MetaConnect:
+				reply.Advice = &Advice{Reconnect: ReconnectRetry, Interval: 10}
+				if atomic.AddInt32(&connects, 1) == 1 {
+					client.client.state.SetClientID(fmt.Sprintf("client-%d", atomic.AddInt32(&sessions, 1)))
+				} else if atomic.LoadInt32(&subscribed) == 1 {
+					replies = append(replies, Message{Channel: "/foo/bar", Data: json.RawMessage(`"event"`)})
+				}
+			}
+			replies = append(replies, reply)
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	var err error
+	client, err = NewClient("https://example.com", WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	msgs := make(chan []Message, 10)
+	if err := client.Subscribe("/foo/bar", msgs); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+
+	select {
+	case ms := <-msgs:
+		if len(ms) != 1 || string(ms[0].Data) != `"event"` {
+			t.Errorf("expected the event, got %v", ms)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the event")
+	}
+	if got := atomic.LoadInt32(&subscribes); got != 2 {
+		t.Errorf("expected the subscription to be sent again in the new session, got %d requests", got)
+	}
+}
+
+// connectReplyTransport replies successfully to every message, answering
+// each /meta/connect with reply
+func connectReplyTransport(reply func(m Message) Message) transportFn {
+	return func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			if m.Channel == MetaConnect {
+				replies = append(replies, reply(m))
+				continue
+			}
+			replies = append(replies, Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true})
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	}
+}
+
+func TestConnectDropsResponseForSupersededConnect(t *testing.T) {
+	var connects int32
+	connecting := make(chan struct{})
+	release := make(chan struct{})
+	transport := connectReplyTransport(func(m Message) Message {
+		if atomic.AddInt32(&connects, 1) == 1 {
+			close(connecting)
+			<-release
+		}
+		return Message{Channel: m.Channel, ID: m.ID, ClientID: m.ClientID, Successful: true}
+	})
+
+	client, err := NewBayeuxClient(nil, transport, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Connect(ctx)
+		done <- err
+	}()
+	<-connecting
+
+	// A second /meta/connect is sent while the first is outstanding
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("expected the latest connect to succeed, got %v", err)
+	}
+	close(release)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrStaleConnect) {
+			t.Errorf("expected ErrStaleConnect, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for connect")
+	}
+}
+
+func TestConnectDropsOutOfOrderResponse(t *testing.T) {
+	transport := transportFn(func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			if m.Channel == MetaConnect {
+				// The reply echoes the id of an earlier request
+				replies = append(replies,
+					Message{Channel: m.Channel, ID: "0", ClientID: m.ClientID, Successful: true, Advice: &Advice{Reconnect: ReconnectNone, Interval: 12345}},
+					Message{Channel: "/foo/bar", Data: json.RawMessage(`"event"`)},
+				)
+				continue
+			}
+			replies = append(replies, Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true})
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	client, err := NewBayeuxClient(nil, transport, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	ms, err := client.Connect(ctx)
+	if !errors.Is(err, ErrStaleConnect) {
+		t.Errorf("expected ErrStaleConnect, got %v", err)
+	}
+	if len(ms) != 1 || ms[0].Channel != "/foo/bar" {
+		t.Errorf("expected only the stale reply to be dropped, got %v", ms)
+	}
+	if advice := client.state.GetAdvice(); advice.Reconnect == ReconnectNone || advice.Interval == 12345 {
+		t.Errorf("expected the advice of the stale reply to be ignored, got %+v", advice)
+	}
+}
+
+func TestAdviceIsInherited(t *testing.T) {
+	transport := transportFn(func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			reply := Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true}
+			// Only the handshake reply carries advice
+			if m.Channel == MetaHandshake {
+				reply.Advice = &Advice{Reconnect: ReconnectRetry, Interval: 60000}
+			}
+			replies = append(replies, reply)
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	clock := newFakeClock()
+	client, err := NewClient("https://example.com", WithHTTPTransport(transport), withClock(clock))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-clock.added:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the client to wait for the advised interval")
+		}
+
+		clock.lock.Lock()
+		wait := clock.waiters[len(clock.waiters)-1].deadline.Sub(clock.now)
+		clock.lock.Unlock()
+		if wait != time.Minute {
+			t.Errorf("expected to wait the interval advised in the handshake, got %s", wait)
+		}
+		clock.Advance(wait)
+	}
+	if got := client.SessionInfo().Interval; got != time.Minute {
+		t.Errorf("expected the session to keep the advised interval, got %s", got)
+	}
+}
+
+func TestClientState_SetSessionFollowsHandshakeAdvice(t *testing.T) {
+	state := clientState{}
+	state.ObserveAdvice(&Advice{Reconnect: ReconnectRetry, Interval: 1000})
+	state.ObserveAdvice(&Advice{Reconnect: ReconnectHandshake, MultipleClients: true})
+	state.SetSession(SessionInfo{ClientID: "abc"})
+
+	advice := state.GetAdvice()
+	if !advice.ShouldRetry() {
+		t.Errorf("expected to retry once the handshake was followed, got %q", advice.Reconnect)
+	}
+	if advice.MultipleClients {
+		t.Error("expected the new session not to inherit multiple-clients advice")
+	}
+	if got := state.GetSession().Interval; got != time.Second {
+		t.Errorf("expected the new session to keep the advised interval, got %s", got)
+	}
+}
+
+func TestHandshakeResponseWithExtraMessages(t *testing.T) {
+	testCases := []struct {
+		name    string
+		extra   Message
+		wantErr error
+	}{
+		{"event", Message{Channel: "/foo/bar", Data: json.RawMessage(`{}`)}, nil},
+		{"second handshake reply", Message{Channel: MetaHandshake, ClientID: "def", Successful: true}, ErrTooManyMessages},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			transport := transportFn(func(r *http.Request) (*http.Response, error) {
+				body, err := json.Marshal([]Message{
+					{Channel: MetaHandshake, ClientID: "abc", Successful: true},
+					tc.extra,
+				})
+				if err != nil {
+					return nil, err
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(bytes.NewReader(body)),
+				}, nil
+			})
+			client, err := NewBayeuxClient(nil, transport, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			ms, err := client.Handshake(context.Background())
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("want error %v, got %v", tc.wantErr, err)
+			}
+			if len(ms) != 2 || ms[1].Channel != tc.extra.Channel {
+				t.Errorf("expected the extra message to be returned, got %v", ms)
+			}
+		})
+	}
+}
+
+func TestPublishReplyWithoutID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		replyID string
+		wantErr error
+	}{
+		{"without id", "", nil},
+		{"matching id", "2", nil},
+		{"other id", "0", ErrNoPublishReply},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			transport := transportFn(func(r *http.Request) (*http.Response, error) {
+				var requests []Message
+				if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+					return nil, err
+				}
+				var replies []Message
+				for _, m := range requests {
+					if m.Channel == "/foo/bar" {
+						// An event on the channel is not mistaken for the reply
+						replies = append(replies,
+							Message{Channel: m.Channel, Data: json.RawMessage(`"event"`)},
+							Message{Channel: m.Channel, ID: tc.replyID, Successful: true},
+						)
+						continue
+					}
+					replies = append(replies, Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true})
+				}
+				body, err := json.Marshal(replies)
+				if err != nil {
+					return nil, err
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(bytes.NewReader(body)),
+				}, nil
+			})
+
+			client, err := NewBayeuxClient(nil, transport, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			ctx := context.Background()
+			if _, err := client.Handshake(ctx); err != nil {
+				t.Fatalf("failed to handshake (%v)", err)
+			}
+			_, err = client.Publish(ctx, "/foo/bar", json.RawMessage(`"hello"`))
+			if tc.wantErr == nil && err != nil {
+				t.Errorf("expected the publish to succeed, got %v", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package gobayeux
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// callbackName is the JSONP callback the server is asked to wrap each
+// callback-polling response in
+const callbackName = "jsonpcallback"
+
+// WithCallbackPolling returns an Option which uses the callback-polling
+// connection type, for servers which do not offer long-polling. Each request
+// is sent as a GET with the encoded messages in the "message" query
+// parameter and each response is expected to be a JSONP call, e.g.,
+// jsonpcallback([...]).
+func WithCallbackPolling() Option {
+	return func(options *Options) {
+		options.CallbackPolling = true
+	}
+}
+
+// newCallbackPollingRequest returns the GET request sending the encoded
+// messages in body to the server at address
+func newCallbackPollingRequest(ctx context.Context, address *url.URL, body []byte) (*http.Request, error) {
+	withMessages := *address
+	query := withMessages.Query()
+	query.Set("message", string(body))
+	query.Set("jsonp", callbackName)
+	withMessages.RawQuery = query.Encode()
+	return http.NewRequestWithContext(ctx, http.MethodGet, withMessages.String(), nil)
+}
+
+// unwrapCallback returns the messages passed to the JSONP callback in body
+func unwrapCallback(body []byte) ([]byte, error) {
+	body = bytes.TrimRight(bytes.TrimSpace(body), ";")
+	prefix := []byte(callbackName + "(")
+	if !bytes.HasPrefix(body, prefix) || !bytes.HasSuffix(body, []byte(")")) {
+		return nil, ErrBadCallbackResponse
+	}
+	return body[len(prefix) : len(body)-1], nil
+}
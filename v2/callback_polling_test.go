@@ -0,0 +1,84 @@
+package gobayeux_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestWithCallbackPolling(t *testing.T) {
+	var sent []gobayeux.Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("expected a GET request, got %s", r.Method)
+		}
+		var ms []gobayeux.Message
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("message")), &ms); err != nil {
+			t.Errorf("expected the messages in the query, got %q (%v)", r.URL.RawQuery, err)
+		}
+		sent = append(sent, ms...)
+
+		reply := gobayeux.Message{Channel: ms[0].Channel, ID: ms[0].ID, ClientID: "abc", Successful: true}
+		if ms[0].Channel == gobayeux.MetaHandshake {
+			reply.SupportedConnectionTypes = []string{gobayeux.ConnectionTypeCallbackPolling}
+		}
+		body, err := json.Marshal([]gobayeux.Message{reply})
+		if err != nil {
+			t.Fatalf("failed to marshal reply (%v)", err)
+		}
+		w.Header().Set("Content-Type", "text/javascript")
+		fmt.Fprintf(w, "%s(%s);", r.URL.Query().Get("jsonp"), body)
+	}))
+	defer server.Close()
+
+	client, err := gobayeux.NewBayeuxClient(nil, nil, server.URL, nil, gobayeux.WithCallbackPolling())
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	ms, err := client.Connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+	if len(ms) != 1 || !ms[0].Successful {
+		t.Errorf("unexpected connect response %v", ms)
+	}
+	if got := client.SessionInfo().ConnectionType; got != gobayeux.ConnectionTypeCallbackPolling {
+		t.Errorf("expected the session to use callback-polling, got %q", got)
+	}
+
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 messages to be sent, got %d", len(sent))
+	}
+	if got := sent[0].SupportedConnectionTypes; len(got) != 1 || got[0] != gobayeux.ConnectionTypeCallbackPolling {
+		t.Errorf("expected the handshake to offer callback-polling, got %v", got)
+	}
+	if got := sent[1].ConnectionType; got != gobayeux.ConnectionTypeCallbackPolling {
+		t.Errorf("expected the connect to use callback-polling, got %q", got)
+	}
+}
+
+func TestCallbackPollingRejectsPlainJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`))
+	}))
+	defer server.Close()
+
+	client, err := gobayeux.NewBayeuxClient(nil, nil, server.URL, nil, gobayeux.WithCallbackPolling())
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if _, err := client.Handshake(context.Background()); !errors.Is(err, gobayeux.ErrBadCallbackResponse) {
+		t.Errorf("expected ErrBadCallbackResponse, got %v", err)
+	}
+}
@@ -2,7 +2,13 @@ package gobayeux
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"math/rand"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -13,25 +19,136 @@ type Client struct {
 	client                    *BayeuxClient
 	subscriptions             *subscriptionsMap
 	logger                    Logger
-	subscribeRequestChannel   chan subscriptionRequest
-	unsubscribeRequestChannel chan Channel
+	subscribeRequestChannel   chan []subscriptionRequest
+	unsubscribeRequestChannel chan []Channel
 	connectRequestChannel     chan struct{}
 	connectMessageChannel     chan []Message
 	handshakeRequestChannel   chan struct{}
 	shutdown                  chan struct{}
+	shutdownOnce              sync.Once
+	closeOnce                 sync.Once
+	closeErr                  error
+	goroutines                sync.WaitGroup
 	ignoreError               IgnoreErrorFunc
+	continueOnError           bool
+	dedupe                    *dedupeFilter
+	clock                     Clock
+	ready                     chan struct{}
+	lifecycleLock             sync.Mutex
+	cancelPoll                context.CancelFunc
+	pollDone                  chan struct{}
+	pollWaitersLock           sync.Mutex
+	pollWaiters               []chan error
+	readyOnce                 sync.Once
+	readyErr                  error
+	reconnectDelay            time.Duration
+	connectJitter             float64
+	lastErrors                *lastErrors
+	deliveryOrder             DeliveryOrderPolicy
+	renewal                   *subscriptionRenewal
+	metrics                   Metrics
+	stats                     *statsCollector
+	statusHandlers            map[int]StatusAction
+	fanOut                    bool
+	onSubscribe               SubscriptionResultFunc
+	onUnsubscribe             SubscriptionResultFunc
+	backoffUntil              int64
+	connectTolerance          *errorTolerance
+	rehandshakeNotices        bool
+	initialSubscriptions      []subscriptionRequest
+	pingChannel               Channel
+	typed                     typedSubscriptions
+	maxDeliveryBatch          int
 }
 
+// Status describes what a Client is currently doing
+type Status struct {
+	// State is the state of the connection to the server or BackoffState
+	// while waiting to reconnect
+	State StateRepresentation
+	// Remaining is how long is left before the client attempts to reconnect
+	// when State is BackoffState
+	Remaining time.Duration
+}
+
+// Operation names used as the keys of the map returned by Client.LastErrors
+const (
+	OperationHandshake   = "handshake"
+	OperationConnect     = "connect"
+	OperationSubscribe   = "subscribe"
+	OperationUnsubscribe = "unsubscribe"
+	OperationDisconnect  = "disconnect"
+	OperationPublish     = "publish"
+)
+
 // IgnoreErrorFunc is a callback function that inspects an error and determines
 // if it can be safely ignored when subscribing and unsubscribing.
 type IgnoreErrorFunc func(error) bool
 
+// SubscriptionResultFunc is a callback function that is given the result of
+// subscribing to or unsubscribing from a channel, nil when it succeeded.
+type SubscriptionResultFunc func(Channel, error)
+
 // Options stores the available configuration options for a Client
 type Options struct {
 	Logger      Logger
 	Client      *http.Client
 	Transport   http.RoundTripper
 	IgnoreError IgnoreErrorFunc
+	// ContinueOnError keeps the Client running after non-fatal subscribe
+	// and unsubscribe errors
+	ContinueOnError bool
+	// DedupeWindow is how many message IDs are remembered on each channel
+	// to drop messages the server delivers again
+	DedupeWindow int
+	// ReconnectSeed, when set, is used to derive a fixed delay applied before
+	// every re-handshake
+	ReconnectSeed *int64
+	// ConnectJitter is the fraction of the advised interval by which each
+	// wait between /meta/connect requests is randomly varied
+	ConnectJitter float64
+	Codec         Codec
+	DeliveryOrder DeliveryOrderPolicy
+	// RenewalInterval is how often every active subscription is re-sent to
+	// the server. ChannelRenewalIntervals overrides it for specific
+	// channels.
+	RenewalInterval         time.Duration
+	ChannelRenewalIntervals map[Channel]time.Duration
+	UserAgent               string
+	Observer                RequestObserver
+	Metrics                 Metrics
+	ACLProbe                Channel
+	HandshakeExt            map[string]interface{}
+	Version                 string
+	MinimumVersion          string
+	StatusHandlers          map[int]StatusAction
+	TLSConfig               *tls.Config
+	MaxIdleConns            int
+	IdleConnTimeout         time.Duration
+	ForceHTTP2              bool
+	ConnectErrorTolerance   int
+	ConnectErrorWindow      time.Duration
+	RehandshakeNotices      bool
+	Subscriptions           []SubscriptionSpec
+	PingChannel             Channel
+	MaxDeliveryBatch        int
+	PayloadLogging          bool
+	PayloadRedactor         PayloadRedactor
+	NegotiatedCodecs        []MediaTypeCodec
+	MetaTimeout             time.Duration
+	HandshakeTimeout        time.Duration
+	ConnectTimeout          time.Duration
+	FanOut                  bool
+	HTTPEncoding            HTTPEncoding
+	CallbackPolling         bool
+	MaxResponseBytes        int64
+	OnSubscribe             SubscriptionResultFunc
+	OnUnsubscribe           SubscriptionResultFunc
+	ChannelBuffers          ChannelBufferConfig
+	RequestInterceptor      MessageInterceptor
+	ResponseInterceptor     MessageInterceptor
+
+	clock Clock
 }
 
 // Option defines the type passed into NewClient for configuration
@@ -51,7 +168,8 @@ func WithFieldLogger(logger logrus.FieldLogger) Option {
 	}
 }
 
-// WithHTTPClient returns an Option with custom http.Client.
+// WithHTTPClient returns an Option with custom http.Client. Its Transport is
+// kept unless WithHTTPTransport is also given.
 func WithHTTPClient(client *http.Client) Option {
 	return func(options *Options) {
 		options.Client = client
@@ -68,6 +186,12 @@ func WithHTTPTransport(transport http.RoundTripper) Option {
 // WithIgnoreError takes a function that will be called whenever an error is
 // returned while subscribing or unsubscribing. If the function returns true,
 // the error will not be considered fatal the the event loop will continue.
+// Use errors.Is with the exported sentinel errors to pick out the conditions
+// to ignore, e.g., subscribing to the same channel twice:
+//
+//	gobayeux.WithIgnoreError(func(err error) bool {
+//		return errors.Is(err, gobayeux.ErrAlreadySubscribed)
+//	})
 //
 // The default is to stop when an error is received.
 func WithIgnoreError(f IgnoreErrorFunc) Option {
@@ -76,6 +200,320 @@ func WithIgnoreError(f IgnoreErrorFunc) Option {
 	}
 }
 
+// WithContinueOnError returns an Option which, when enabled, keeps the Client
+// running after non-fatal subscribe and unsubscribe errors: the server
+// rejecting a channel or subscribing to a channel twice. These errors are
+// still sent on the error channel. Transport and protocol errors stop the
+// Client as before unless the function given to WithIgnoreError ignores
+// them.
+func WithContinueOnError(continueOnError bool) Option {
+	return func(options *Options) {
+		options.ContinueOnError = continueOnError
+	}
+}
+
+// WithDedupeWindow returns an Option which remembers the IDs of the last n
+// messages delivered on each channel and drops any message the server
+// delivers again with one of those IDs. Combined with the ack or replay
+// extensions, which have the server resend messages a reconnecting client
+// may have missed, this delivers each message once on a best-effort basis.
+func WithDedupeWindow(n int) Option {
+	return func(options *Options) {
+		options.DedupeWindow = n
+	}
+}
+
+// WithReconnectSeed takes a per-instance seed (e.g., a hash of the hostname)
+// from which a deterministic delay is derived and applied before each
+// re-handshake. Clients using different seeds will spread their reconnects
+// across the reconnect window instead of reconnecting all at once.
+//
+// The default is to re-handshake immediately.
+func WithReconnectSeed(seed int64) Option {
+	return func(options *Options) {
+		options.ReconnectSeed = &seed
+	}
+}
+
+// WithConnectJitter returns an Option which randomly varies each wait for
+// the interval advised by the server by up to fraction of it in either
+// direction, e.g., 0.1 for ±10%, so that a fleet of clients given the same
+// advice do not all connect at the same instant. fraction is capped at 1,
+// i.e., a wait is never negative nor longer than twice the interval.
+//
+// The default is to wait exactly the advised interval.
+func WithConnectJitter(fraction float64) Option {
+	return func(options *Options) {
+		options.ConnectJitter = fraction
+	}
+}
+
+// WithCodec returns an Option with a custom Codec used to marshal and
+// unmarshal messages.
+//
+// The default is to use encoding/json.
+func WithCodec(codec Codec) Option {
+	return func(options *Options) {
+		options.Codec = codec
+	}
+}
+
+// WithCrossChannelDeliveryOrder returns an Option with the policy used to
+// order the delivery of batches for different channels received in the same
+// /meta/connect response. Messages within a channel are always delivered in
+// the order they were received.
+//
+// The default is FirstSeenDeliveryOrder.
+func WithCrossChannelDeliveryOrder(policy DeliveryOrderPolicy) Option {
+	return func(options *Options) {
+		options.DeliveryOrder = policy
+	}
+}
+
+// WithMaxDeliveryBatch returns an Option which splits the messages for a
+// channel in a /meta/connect response into batches of at most n before they
+// are delivered, in order, to its receivers. By default each channel's
+// messages are delivered in a single batch.
+func WithMaxDeliveryBatch(n int) Option {
+	return func(options *Options) {
+		options.MaxDeliveryBatch = n
+	}
+}
+
+// WithSubscriptionRenewal returns an Option which periodically re-sends the
+// /meta/subscribe request for every active subscription. This is useful with
+// servers that expire subscriptions after some time-to-live even while the
+// session is still alive. interval should be comfortably shorter than the
+// server's time-to-live as renewals may be up to half an interval late.
+//
+// The default is to never renew subscriptions.
+func WithSubscriptionRenewal(interval time.Duration) Option {
+	return func(options *Options) {
+		options.RenewalInterval = interval
+	}
+}
+
+// WithChannelSubscriptionRenewal returns an Option which renews the
+// subscription to channel every interval, overriding any interval set with
+// WithSubscriptionRenewal. An interval of zero disables renewal of channel.
+func WithChannelSubscriptionRenewal(channel Channel, interval time.Duration) Option {
+	return func(options *Options) {
+		if options.ChannelRenewalIntervals == nil {
+			options.ChannelRenewalIntervals = make(map[Channel]time.Duration)
+		}
+		options.ChannelRenewalIntervals[channel] = interval
+	}
+}
+
+// WithUserAgent returns an Option with the User-Agent header sent with every
+// request to the Bayeux server.
+//
+// The default is DefaultUserAgent.
+func WithUserAgent(userAgent string) Option {
+	return func(options *Options) {
+		options.UserAgent = userAgent
+	}
+}
+
+// WithRequestObserver returns an Option with a RequestObserver notified
+// around every request made to the Bayeux server.
+func WithRequestObserver(observer RequestObserver) Option {
+	return func(options *Options) {
+		options.Observer = observer
+	}
+}
+
+// WithMetrics returns an Option with the Metrics that counters and timings
+// are reported to.
+func WithMetrics(metrics Metrics) Option {
+	return func(options *Options) {
+		options.Metrics = metrics
+	}
+}
+
+// WithACLProbe returns an Option which asks the server, over the given
+// /service/ channel, whether each channel is permitted before subscribing to
+// it. Channels the server denies are reported with an AuthorizationError.
+func WithACLProbe(probe Channel) Option {
+	return func(options *Options) {
+		options.ACLProbe = probe
+	}
+}
+
+// WithBayeuxVersion returns an Option with the protocol version sent in the
+// handshake.
+//
+// The default is 1.0.
+func WithBayeuxVersion(version string) Option {
+	return func(options *Options) {
+		options.Version = version
+	}
+}
+
+// WithMinimumBayeuxVersion returns an Option with the oldest protocol
+// version the client accepts, sent as the handshake's minimumVersion.
+//
+// The default is the version given to WithBayeuxVersion.
+func WithMinimumBayeuxVersion(version string) Option {
+	return func(options *Options) {
+		options.MinimumVersion = version
+	}
+}
+
+// WithHandshakeExt returns an Option which adds each key and value of ext to
+// the ext field of every handshake request, e.g., for an OAuth token or
+// session identifier the server requires, without writing a
+// MessageExtender.
+func WithHandshakeExt(ext map[string]interface{}) Option {
+	return func(options *Options) {
+		options.HandshakeExt = ext
+	}
+}
+
+// WithFanOut returns an Option which allows subscribing to a channel more
+// than once, each time with another receiver. Every batch of messages on the
+// channel is then delivered to all of its receivers. Without it, subscribing
+// again fails with ErrAlreadySubscribed.
+func WithFanOut() Option {
+	return func(options *Options) {
+		options.FanOut = true
+	}
+}
+
+// WithOnSubscribe returns an Option which calls f with the result for each
+// channel once the server has responded to a request to subscribe made by
+// Subscribe, SubscribeMany or AddListener. It is called from the polling
+// loop so it must not block.
+func WithOnSubscribe(f SubscriptionResultFunc) Option {
+	return func(options *Options) {
+		options.OnSubscribe = f
+	}
+}
+
+// WithOnUnsubscribe returns an Option which calls f with the result for each
+// channel once the server has responded to a request to unsubscribe made by
+// Unsubscribe or RemoveListener. It is called from the polling loop so it
+// must not block.
+func WithOnUnsubscribe(f SubscriptionResultFunc) Option {
+	return func(options *Options) {
+		options.OnUnsubscribe = f
+	}
+}
+
+// ChannelBufferConfig sets the capacity of the channels a Client queues its
+// work on. A capacity which is not positive keeps the default.
+type ChannelBufferConfig struct {
+	// Subscribe is how many calls to Subscribe, SubscribeMany and
+	// AddListener can be queued before they block. The default is 10.
+	Subscribe int
+	// Unsubscribe is how many calls to Unsubscribe and RemoveListener can
+	// be queued before they block. The default is 10.
+	Unsubscribe int
+	// Connect is how many /meta/connect requests can be queued. The
+	// default is 1.
+	Connect int
+	// ConnectMessage is how many /meta/connect replies can be waiting for
+	// their advice to be followed. The default is 5.
+	ConnectMessage int
+}
+
+// withDefaults returns the config with every capacity which is not positive
+// replaced by its default
+func (config ChannelBufferConfig) withDefaults() ChannelBufferConfig {
+	defaults := ChannelBufferConfig{Subscribe: 10, Unsubscribe: 10, Connect: 1, ConnectMessage: 5}
+	if config.Subscribe <= 0 {
+		config.Subscribe = defaults.Subscribe
+	}
+	if config.Unsubscribe <= 0 {
+		config.Unsubscribe = defaults.Unsubscribe
+	}
+	if config.Connect <= 0 {
+		config.Connect = defaults.Connect
+	}
+	if config.ConnectMessage <= 0 {
+		config.ConnectMessage = defaults.ConnectMessage
+	}
+	return config
+}
+
+// WithChannelBuffers returns an Option which sets the capacities of the
+// channels the Client queues its work on
+func WithChannelBuffers(config ChannelBufferConfig) Option {
+	return func(options *Options) {
+		options.ChannelBuffers = config
+	}
+}
+
+// WithMaxResponseBytes returns an Option which limits every response body,
+// after decompression, to n bytes. A larger response fails its request with
+// a ResponseTooLargeError. There is no limit by default.
+func WithMaxResponseBytes(n int64) Option {
+	return func(options *Options) {
+		options.MaxResponseBytes = n
+	}
+}
+
+// WithRequestInterceptor returns an Option with a MessageInterceptor given
+// every batch of messages just before it is sent, after the extensions have
+// run. The batch it returns is sent instead and an error it returns aborts
+// the request.
+func WithRequestInterceptor(interceptor MessageInterceptor) Option {
+	return func(options *Options) {
+		options.RequestInterceptor = interceptor
+	}
+}
+
+// WithResponseInterceptor returns an Option with a MessageInterceptor given
+// every batch of messages decoded from a response, before the extensions
+// run. The batch it returns is used instead and an error it returns fails
+// the request.
+func WithResponseInterceptor(interceptor MessageInterceptor) Option {
+	return func(options *Options) {
+		options.ResponseInterceptor = interceptor
+	}
+}
+
+// WithRehandshakeNotices returns an Option which reports a RehandshakeNotice
+// on the channel returned by Start each time the Client discards its session
+// and handshakes again, e.g., so that an application can clear its caches.
+// By default this happens silently.
+func WithRehandshakeNotices() Option {
+	return func(options *Options) {
+		options.RehandshakeNotices = true
+	}
+}
+
+// SubscriptionSpec is a channel to subscribe to and where to deliver its
+// messages
+type SubscriptionSpec struct {
+	Channel   Channel
+	Receiving chan []Message
+}
+
+// WithSubscriptions returns an Option which subscribes to every channel in
+// specs, in the given order, right after the handshake and before WaitReady
+// returns. Like those made with Subscribe they are subscribed to
+// again after every re-handshake.
+func WithSubscriptions(specs []SubscriptionSpec) Option {
+	return func(options *Options) {
+		options.Subscriptions = specs
+	}
+}
+
+// DefaultPingChannel is the channel Client.Ping publishes to unless one is
+// provided with WithPingChannel
+const DefaultPingChannel Channel = "/service/ping"
+
+// WithPingChannel returns an Option with the /service/ channel that
+// Client.Ping publishes to.
+//
+// The default is DefaultPingChannel.
+func WithPingChannel(ch Channel) Option {
+	return func(options *Options) {
+		options.PingChannel = ch
+	}
+}
+
 // NewClient creates a new high-level client
 func NewClient(serverAddress string, opts ...Option) (*Client, error) {
 	options := &Options{}
@@ -97,65 +535,443 @@ func NewClient(serverAddress string, opts ...Option) (*Client, error) {
 		}
 	}
 
-	bc, err := NewBayeuxClient(options.Client, options.Transport, serverAddress, options.Logger)
+	if options.Metrics == nil {
+		options.Metrics = nullMetrics{}
+	}
+
+	if options.clock == nil {
+		options.clock = realClock{}
+	}
+
+	// The client keeps its own counters for WriteMetrics alongside any
+	// configured Metrics
+	stats := newStatsCollector()
+	options.Metrics = multiMetrics{stats, options.Metrics}
+
+	if options.DeliveryOrder == nil {
+		options.DeliveryOrder = FirstSeenDeliveryOrder()
+	}
+
+	if options.PingChannel == "" {
+		options.PingChannel = DefaultPingChannel
+	}
+	if options.PingChannel.Type() != ServiceChannel {
+		return nil, InvalidChannelError{options.PingChannel}
+	}
+
+	bayeuxOpts := append(opts[:len(opts):len(opts)], WithMetrics(options.Metrics))
+	bc, err := NewBayeuxClient(options.Client, options.Transport, serverAddress, options.Logger, bayeuxOpts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{
+	var reconnectDelay time.Duration
+	if options.ReconnectSeed != nil {
+		reconnectDelay = reconnectOffset(*options.ReconnectSeed, defaultReconnectWindow)
+	}
+
+	buffers := options.ChannelBuffers.withDefaults()
+	c := &Client{
 		client:                    bc,
 		subscriptions:             newSubscriptionsMap(),
-		subscribeRequestChannel:   make(chan subscriptionRequest, 10),
-		unsubscribeRequestChannel: make(chan Channel, 10),
-		connectRequestChannel:     make(chan struct{}, 1),
-		connectMessageChannel:     make(chan []Message, 5),
-		handshakeRequestChannel:   make(chan struct{}),
+		subscribeRequestChannel:   make(chan []subscriptionRequest, buffers.Subscribe),
+		unsubscribeRequestChannel: make(chan []Channel, buffers.Unsubscribe),
+		connectRequestChannel:     make(chan struct{}, buffers.Connect),
+		connectMessageChannel:     make(chan []Message, buffers.ConnectMessage),
+		handshakeRequestChannel:   make(chan struct{}, 1),
 		shutdown:                  make(chan struct{}),
+		clock:                     options.clock,
+		ready:                     make(chan struct{}),
 		logger:                    options.Logger,
 		ignoreError:               options.IgnoreError,
-	}, nil
+		continueOnError:           options.ContinueOnError,
+		reconnectDelay:            reconnectDelay,
+		connectJitter:             options.ConnectJitter,
+		lastErrors:                newLastErrors(),
+		deliveryOrder:             options.DeliveryOrder,
+		renewal:                   newSubscriptionRenewal(options.RenewalInterval, options.ChannelRenewalIntervals),
+		metrics:                   options.Metrics,
+		stats:                     stats,
+		statusHandlers:            options.StatusHandlers,
+		fanOut:                    options.FanOut,
+		onSubscribe:               options.OnSubscribe,
+		onUnsubscribe:             options.OnUnsubscribe,
+		connectTolerance:          newErrorTolerance(options.ConnectErrorTolerance, options.ConnectErrorWindow),
+		rehandshakeNotices:        options.RehandshakeNotices,
+		pingChannel:               options.PingChannel,
+		maxDeliveryBatch:          options.MaxDeliveryBatch,
+	}
+	if options.DedupeWindow > 0 {
+		c.dedupe = newDedupeFilter(options.DedupeWindow)
+	}
+	// The polling loop follows the advice in /meta/connect replies through
+	// this receiver; users may add their own alongside it
+	_ = c.subscriptions.Add(MetaConnect, c.connectMessageChannel)
+	for _, spec := range options.Subscriptions {
+		if spec.Channel == MetaConnect {
+			c.subscriptions.AddReceiver(MetaConnect, spec.Receiving)
+			continue
+		}
+		c.initialSubscriptions = append(c.initialSubscriptions, subscriptionRequest{spec.Channel, spec.Receiving, false})
+	}
+	return c, nil
 }
 
-// Subscribe queues a request to subscribe to a new channel from the server
-func (c *Client) Subscribe(ch Channel, receiving chan []Message) {
-	c.subscribeRequestChannel <- subscriptionRequest{ch, receiving}
+// Subscribe queues a request to subscribe to a new channel from the server.
+// It returns ErrClientClosed once the client has been disconnected.
+//
+// Each /meta/connect response delivers one batch per channel to receiving
+// holding that channel's messages in the order the server sent them, even
+// when the server interleaves them with messages for other channels.
+//
+// Subscribing to MetaConnect instead adds receiving as another receiver of
+// the /meta/connect replies, and their advice, which the client already
+// handles itself. No request is made to the server.
+func (c *Client) Subscribe(ch Channel, receiving chan []Message) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	if ch == MetaConnect {
+		c.subscriptions.AddReceiver(MetaConnect, receiving)
+		return nil
+	}
+	select {
+	case c.subscribeRequestChannel <- []subscriptionRequest{{ch, receiving, false}}:
+		return nil
+	case <-c.shutdown:
+		return ErrClientClosed
+	}
 }
 
-// Unsubscribe queues a request to unsubscribe from a channel on the server
-func (c *Client) Unsubscribe(ch Channel) {
-	c.unsubscribeRequestChannel <- ch
+// SubscribeMany queues a request to subscribe to all of channels, delivering
+// their messages to receiving, like calling Subscribe for each of them
+// except that they are always sent to the server in a single /meta/subscribe
+// request. A failure is reported as one SubscriptionFailedError covering
+// every channel.
+func (c *Client) SubscribeMany(channels []Channel, receiving chan []Message) error {
+	if len(channels) == 0 {
+		return EmptySliceError("channels")
+	}
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	subReqs := make([]subscriptionRequest, 0, len(channels))
+	for _, ch := range channels {
+		if ch == MetaConnect {
+			c.subscriptions.AddReceiver(MetaConnect, receiving)
+			continue
+		}
+		subReqs = append(subReqs, subscriptionRequest{ch, receiving, false})
+	}
+	if len(subReqs) == 0 {
+		return nil
+	}
+	select {
+	case c.subscribeRequestChannel <- subReqs:
+		return nil
+	case <-c.shutdown:
+		return ErrClientClosed
+	}
+}
+
+// AddListener adds receiving as another receiver of the messages on ch
+// alongside those already subscribed, even without WithFanOut. If ch is not
+// subscribed to yet, it queues a request to subscribe with receiving as
+// Subscribe does. It returns ErrClientClosed once the client has been
+// disconnected.
+func (c *Client) AddListener(ch Channel, receiving chan []Message) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	if c.subscriptions.AddListener(ch, receiving) {
+		return nil
+	}
+	select {
+	case c.subscribeRequestChannel <- []subscriptionRequest{{ch, receiving, true}}:
+		return nil
+	case <-c.shutdown:
+		return ErrClientClosed
+	}
+}
+
+// RemoveListener stops delivering the messages on ch to receiving, which
+// must have been given to Subscribe, SubscribeMany or AddListener. Once the
+// last receiver of ch is removed it queues a request to unsubscribe from ch
+// on the server. It returns an error wrapping ErrNotSubscribed if receiving
+// is not a receiver of ch.
+func (c *Client) RemoveListener(ch Channel, receiving chan []Message) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	remaining, err := c.subscriptions.RemoveReceiver(ch, receiving)
+	if err != nil {
+		return err
+	}
+	if remaining > 0 || ch == MetaConnect {
+		return nil
+	}
+	return c.Unsubscribe(ch)
+}
+
+// Unsubscribe queues a request to unsubscribe from a channel on the server.
+// Unsubscribing from MetaConnect removes any receivers added by Subscribe.
+// It returns ErrClientClosed once the client has been disconnected.
+func (c *Client) Unsubscribe(ch Channel) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	if ch == MetaConnect {
+		c.subscriptions.Replace(MetaConnect, c.connectMessageChannel)
+		return nil
+	}
+	select {
+	case c.unsubscribeRequestChannel <- []Channel{ch}:
+		return nil
+	case <-c.shutdown:
+		return ErrClientClosed
+	}
+}
+
+// UnsubscribeAll queues a request to unsubscribe from every channel
+// currently subscribed to, in a single /meta/unsubscribe request. Receivers
+// of MetaConnect are left in place. It returns ErrClientClosed once the
+// client has been disconnected.
+func (c *Client) UnsubscribeAll() error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	channels := c.subscriptions.List()
+	if len(channels) == 0 {
+		return nil
+	}
+	select {
+	case c.unsubscribeRequestChannel <- channels:
+		return nil
+	case <-c.shutdown:
+		return ErrClientClosed
+	}
+}
+
+// isClosed reports whether Disconnect has been called
+func (c *Client) isClosed() bool {
+	select {
+	case <-c.shutdown:
+		return true
+	default:
+		return false
+	}
 }
 
 // Start begins the background process that talks to the server
 func (c *Client) Start(ctx context.Context) <-chan error {
 	errors := make(chan error)
-	go c.start(ctx, errors)
+	// Disconnect cancels the requests in flight and waits for the polling
+	// loop to stop
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	c.lifecycleLock.Lock()
+	c.cancelPoll = cancel
+	c.pollDone = done
+	c.lifecycleLock.Unlock()
+	c.goroutines.Add(1)
+	go func() {
+		defer c.goroutines.Done()
+		defer close(done)
+		defer cancel()
+		c.start(ctx, errors)
+	}()
 	return errors
 }
 
 // Disconnect issues a /meta/disconnect request to the Bayeux server and then
-// cleans up channels and our timer.
+// stops the long-polling loop.
+//
+// The loop is stopped, and any request it has in flight cancelled, before
+// the server is contacted, so the client is shut down even when the
+// /meta/disconnect request fails or ctx expires. The server's error is still
+// returned.
 func (c *Client) Disconnect(ctx context.Context) error {
+	// The request channels are left open as timers started by the polling
+	// loop may still be sending on them
+	c.shutdownOnce.Do(func() { close(c.shutdown) })
+	c.lifecycleLock.Lock()
+	cancel, done := c.cancelPoll, c.pollDone
+	c.lifecycleLock.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+
 	_, err := c.client.Disconnect(ctx)
-	close(c.subscribeRequestChannel)
-	close(c.unsubscribeRequestChannel)
-	close(c.connectRequestChannel)
-	close(c.connectMessageChannel)
-	close(c.handshakeRequestChannel)
+	if err != nil {
+		c.recordError(OperationDisconnect, err)
+	}
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+	}
 	return err
 }
 
-// Publish is not yet implemented. When implemented, it will - in a separate thread
-// from the polling task - publish messages to the Bayeux Server.
+// DisconnectWithTimeout calls Disconnect with a context which expires after
+// timeout, for callers without a context of their own.
+func (c *Client) DisconnectWithTimeout(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return c.Disconnect(ctx)
+}
+
+// closeTimeout bounds the /meta/disconnect request sent by Close
+const closeTimeout = 5 * time.Second
+
+// Close disconnects from the server, unless Disconnect has already been
+// called, and then waits for every goroutine the Client started to exit so
+// that nothing is left running or sending afterwards. The /meta/disconnect
+// request is given closeTimeout to complete. Close is safe to call more than
+// once and always returns the error from the first call.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		if !c.isClosed() {
+			c.closeErr = c.DisconnectWithTimeout(closeTimeout)
+			if errors.Is(c.closeErr, ErrClientNotConnected) {
+				// A handshake never completed so there is no session to end
+				c.closeErr = nil
+			}
+		}
+		c.lifecycleLock.Lock()
+		cancel := c.cancelPoll
+		c.lifecycleLock.Unlock()
+		if cancel != nil {
+			cancel()
+		}
+	})
+	c.goroutines.Wait()
+	return c.closeErr
+}
+
+// Publish publishes the Data of each of messages on its Channel in turn,
+// like PublishSync, stopping at the first that fails. Other fields of the
+// messages are ignored. Each publish is sent in its own request alongside
+// the /meta/connect long poll.
 //
 // See also: https://docs.cometd.org/current/reference/#_two_connection_operation
 func (c *Client) Publish(ctx context.Context, messages []Message) error {
-	// TODO:
-	// * Locking mechanism to ensure only one outstanding Publish request at a
-	//   time
-	// * Ensure that this separate from Start()/poll()
-	// * Implement Publish() in *BayeuxClient
-	panic("Publish() is not yet implemented")
+	for _, m := range messages {
+		if err := c.PublishSync(ctx, m.Channel, m.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishSync publishes data on channel and waits until the server replies
+// or ctx is done. It returns a PublishFailedError when the server rejects
+// the message. The publish is sent in its own request alongside the
+// /meta/connect long poll, so it neither waits for nor interrupts it.
+func (c *Client) PublishSync(ctx context.Context, channel Channel, data json.RawMessage) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	if _, err := c.client.Publish(ctx, channel, data); err != nil {
+		return c.recordError(OperationPublish, err)
+	}
+	return nil
+}
+
+// Ping checks that the server is reachable and still knows the session by
+// publishing an empty message to the ping channel and waiting for the
+// server's reply or for ctx to be done. Like PublishSync it uses its own
+// request so it is safe to call while the Client is polling.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	_, err := c.client.Publish(ctx, c.pingChannel, json.RawMessage("{}"))
+	return err
+}
+
+// State reports the current Status of the client
+func (c *Client) State() Status {
+	if until := atomic.LoadInt64(&c.backoffUntil); until != 0 {
+		if remaining := time.Unix(0, until).Sub(c.clock.Now()); remaining > 0 {
+			return Status{State: BackoffState, Remaining: remaining}
+		}
+	}
+	return Status{State: c.client.stateMachine.CurrentState()}
+}
+
+// LastErrors returns the most recent error seen for each operation (see the
+// Operation constants) that has failed at least once. This allows inspecting
+// current problem areas without consuming the channel returned by Start.
+func (c *Client) LastErrors() map[string]error {
+	return c.lastErrors.Snapshot()
+}
+
+// WaitReady blocks until the first handshake started by Start, and the
+// subscriptions given with WithSubscriptions, complete. It returns the error
+// that stopped the Client if either failed or ctx's error if ctx is done
+// first.
+func (c *Client) WaitReady(ctx context.Context) error {
+	select {
+	case <-c.ready:
+		return c.readyErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Poll starts a /meta/connect straight away instead of waiting for the
+// interval advised by the server, e.g., when the caller knows an event is
+// imminent. It blocks until a /meta/connect started after the call has
+// completed and its messages have been delivered, and returns that
+// request's error. As with any /meta/connect the server may hold the request
+// until it has messages for the client. It returns ctx's error if ctx is done
+// first and ErrClientClosed once the client has been disconnected.
+func (c *Client) Poll(ctx context.Context) error {
+	if c.isClosed() {
+		return ErrClientClosed
+	}
+	waiter := make(chan error, 1)
+	c.pollWaitersLock.Lock()
+	c.pollWaiters = append(c.pollWaiters, waiter)
+	c.pollWaitersLock.Unlock()
+	c.enqueueConnectRequest()
+
+	select {
+	case err := <-waiter:
+		return err
+	case <-c.shutdown:
+		return ErrClientClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// takePollWaiters returns the callers of Poll waiting for the next
+// /meta/connect to complete
+func (c *Client) takePollWaiters() []chan error {
+	c.pollWaitersLock.Lock()
+	defer c.pollWaitersLock.Unlock()
+	waiters := c.pollWaiters
+	c.pollWaiters = nil
+	return waiters
+}
+
+// notifyPollWaiters tells the callers of Poll how their /meta/connect went
+func notifyPollWaiters(waiters []chan error, err error) error {
+	for _, waiter := range waiters {
+		waiter <- err
+	}
+	return err
+}
+
+// markReady records the outcome of the first handshake for WaitReady
+func (c *Client) markReady(err error) {
+	c.readyOnce.Do(func() {
+		c.readyErr = err
+		close(c.ready)
+	})
 }
 
 // UseExtension adds the provided MessageExtender as an extension for use with
@@ -166,29 +982,89 @@ func (c *Client) UseExtension(ext MessageExtender) error {
 	return c.client.UseExtension(ext)
 }
 
+// RemoveExtension removes the provided MessageExtender from this Client
+// session. It returns a NotRegisteredError if the extension is not in use.
+func (c *Client) RemoveExtension(ext MessageExtender) error {
+	return c.client.RemoveExtension(ext)
+}
+
 func (c *Client) start(ctx context.Context, errors chan error) {
 	logger := c.logger.WithField("at", "start")
-	if _, err := c.client.Handshake(ctx); err != nil {
-		errors <- err
+	ms, err := c.client.Handshake(ctx)
+	if err != nil {
+		err = c.recordError(OperationHandshake, err)
+		c.markReady(err)
+		c.sendError(errors, err)
 		return
 	}
-
-	_ = c.subscriptions.Add(MetaConnect, c.connectMessageChannel)
+	if len(c.initialSubscriptions) > 0 {
+		if err := c.subscribe(ctx, errors, c.initialSubscriptions); err != nil {
+			c.markReady(err)
+			c.sendError(errors, err)
+			return
+		}
+	}
+	if err := c.deliverFromHandshake(logger, ms); err != nil {
+		c.markReady(err)
+		return
+	}
+	c.markReady(nil)
 
 	logger.Debug("starting long-polling loop")
-	if err := c.poll(ctx, errors); err != nil {
-		errors <- err
+	if err := c.poll(ctx, errors); err != nil && !c.isClosed() {
+		c.sendError(errors, err)
 		return
 	}
+}
 
-	if _, err := c.client.Disconnect(ctx); err != nil {
-		errors <- err
-		return
+// deliverFromHandshake delivers the events some servers send alongside the
+// handshake reply to the channels already subscribed to, i.e., those given
+// with WithSubscriptions. It returns ErrClientClosed if the client is
+// disconnected part way through.
+func (c *Client) deliverFromHandshake(logger Logger, ms []Message) error {
+	events := make([]Message, 0, len(ms))
+	for _, m := range ms {
+		if m.Channel.Type() == MetaChannel {
+			continue
+		}
+		if _, err := c.subscriptions.Get(m.Channel); err != nil {
+			logger.WithField("channel", m.Channel).Debug("dropping message sent with the handshake for a channel not subscribed to")
+			continue
+		}
+		events = append(events, m)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	logger.WithField("messages", len(events)).Debug("delivering messages sent with the handshake")
+	return c.deliver(logger, events)
+}
+
+// sendError reports err on the channel returned by Start unless the client
+// is disconnected first, when nobody may be left to receive it
+func (c *Client) sendError(errors chan<- error, err error) {
+	select {
+	case errors <- err:
+	case <-c.shutdown:
 	}
 }
 
 func (c *Client) poll(ctx context.Context, errors chan<- error) error {
 	logger := c.logger.WithField("at", "poll")
+
+	// renewals fires every tick on the same clock that subscriptions are
+	// tracked with
+	var renewals <-chan time.Time
+	tick := c.renewal.TickInterval()
+	if tick > 0 {
+		renewals = c.clock.After(tick)
+	}
+
+	// nextConnect fires once the interval advised by the server has elapsed.
+	// Only the most recently scheduled interval is ever waited on so that
+	// there is never more than one pending /meta/connect.
+	var nextConnect <-chan time.Time
+	c.enqueueConnectRequest()
 _poll_loop:
 	for {
 		logger.Debug("in polling loop")
@@ -203,45 +1079,53 @@ _poll_loop:
 			}
 			logger.Debug("shutting down due to cancelled context")
 			break _poll_loop
-		case subReq := <-c.subscribeRequestChannel:
+		case reqs := <-c.subscribeRequestChannel:
 			logger.Debug("got subscription requests")
 			// Let's attempt to drain the channel before sending a
 			// /meta/unsubscribe request to more efficiently use HTTP
 			// requests
-			subReqs, channels := c.getSubscriptionRequests()
-			subReqs = append(subReqs, subReq)
-			channels = append(channels, subReq.subscription)
-			// TODO: Find a way to consolidate this logic and the logic in
-			// start()
-			if _, err := c.client.Subscribe(ctx, channels); err != nil {
-				if c.ignoreError(err) {
-					errors <- err
-					continue
-				}
-
+			// Keep the order the requests were made in so a Subscribe is
+			// added before an AddListener for the same channel
+			subReqs := append(reqs, c.getSubscriptionRequests()...)
+			if err := c.subscribe(ctx, errors, subReqs); err != nil {
 				return err
 			}
 
-			for _, subReq := range subReqs {
-				if err := c.subscriptions.Add(subReq.subscription, subReq.msgChan); err != nil {
-					if c.ignoreError(err) {
-						errors <- err
-						continue
-					}
-
-					return err
-				}
-			}
-
 			c.enqueueConnectRequest()
 
-		case unsubReq := <-c.unsubscribeRequestChannel:
+		case unsubReqs := <-c.unsubscribeRequestChannel:
 			logger.Debug("got unsubscribe requests")
 			channels := c.getUnsubscriptionRequests()
-			channels = append(channels, unsubReq)
-			if _, err := c.client.Unsubscribe(ctx, channels); err != nil {
-				if c.ignoreError(err) {
-					errors <- err
+			channels = append(channels, unsubReqs...)
+			response, err := c.client.Unsubscribe(ctx, channels)
+			if isStaleSession(err) {
+				// The request went out in a session that has since been
+				// replaced so unsubscribe in the current one
+				logger.WithError(err).Debug("unsubscribing again in the current session")
+				response, err = c.client.Unsubscribe(ctx, channels)
+			}
+			if err != nil && handshakeAdvised(response) {
+				// The server forgot the subscriptions along with the
+				// session so there is nothing left to unsubscribe from
+				logger.WithError(err).Debug("re-handshaking as advised by /meta/unsubscribe")
+				c.recordError(OperationUnsubscribe, err)
+				for _, channel := range channels {
+					c.subscriptions.Remove(channel)
+					c.renewal.Forget(channel)
+					c.typed.unsubscribed(channel)
+				}
+				notifyResults(c.onUnsubscribe, channels, nil)
+				if err := c.rehandshake(ctx, errors); err != nil {
+					return err
+				}
+				c.enqueueConnectRequest()
+				continue
+			}
+			notifyResults(c.onUnsubscribe, channels, err)
+			if err != nil {
+				c.recordError(OperationUnsubscribe, err)
+				if c.canContinue(err) {
+					c.sendError(errors, err)
 					continue
 				}
 
@@ -250,80 +1134,368 @@ _poll_loop:
 
 			for _, channel := range channels {
 				c.subscriptions.Remove(channel)
+				c.renewal.Forget(channel)
+				c.typed.unsubscribed(channel)
+			}
+
+		case now := <-renewals:
+			renewals = c.clock.After(tick)
+			channels := c.renewal.Due(now)
+			if len(channels) == 0 {
+				continue
+			}
+			logger.WithField("channels", channels).Debug("renewing subscriptions")
+			// Subscribing is idempotent so the server simply extends the
+			// subscriptions we already have
+			if _, err := c.client.Subscribe(ctx, channels); err != nil {
+				if isStaleSession(err) {
+					// They are renewed on the next tick in the current
+					// session
+					logger.WithError(err).Debug("ignoring renewal response for replaced session")
+					continue
+				}
+				c.recordError(OperationSubscribe, err)
+				if !c.canContinue(err) {
+					return err
+				}
+
+				c.sendError(errors, err)
+				channels = acceptedChannels(err)
+			}
+			for _, channel := range channels {
+				c.renewal.Track(channel, now)
 			}
 
 		case <-c.handshakeRequestChannel:
-			logger.Debug("re-handshaking")
-			if _, err := c.client.Handshake(ctx); err != nil {
+			if err := c.rehandshake(ctx, errors); err != nil {
 				return err
 			}
 			c.enqueueConnectRequest()
-		case ms := <-c.connectMessageChannel:
+		case <-c.connectMessageChannel:
 			logger.Debug("handling messages from /meta/connect")
-			for _, m := range ms {
-				if m.Advice.ShouldHandshake() {
-					logger.Debug("queueing new handshake request")
-					c.handshakeRequestChannel <- struct{}{}
-				}
-				interval := m.Advice.IntervalAsDuration()
-				go func() {
-					c.logger.WithField("interval", interval).Debug("waiting per advice")
-					<-time.After(interval)
-					c.enqueueConnectRequest()
-				}()
+			// The server may leave out advice it has already given so
+			// follow everything it has advised so far
+			advice := c.client.state.GetAdvice()
+			if advice.MultipleClients {
+				// Another connection is using our clientID so the session
+				// can no longer be trusted to be ours alone
+				logger.Warn("server detected multiple clients, re-handshaking")
+				c.sendError(errors, c.recordError(OperationConnect, ErrMultipleClients))
+				nextConnect = nil
+				c.enqueueHandshakeRequest()
+				continue
 			}
+			if advice.ShouldHandshake() {
+				nextConnect = nil
+				c.enqueueHandshakeRequest()
+				continue
+			}
+			interval := jitterInterval(advice.IntervalAsDuration(), c.connectJitter, rand.Float64())
+			logger.WithField("interval", interval).Debug("waiting per advice")
+			nextConnect = c.clock.After(interval)
+
+		case <-nextConnect:
+			nextConnect = nil
+			c.enqueueConnectRequest()
 
 		case <-c.connectRequestChannel:
 			logger.Debug("checking for new messages")
+			waiters := c.takePollWaiters()
 			ms, err := c.client.Connect(ctx)
+			if err != nil && c.isClosed() {
+				// Disconnect cancelled the request
+				return nil
+			}
+			if isStale(err) {
+				// The reply answered an earlier request so there is no
+				// advice to wait on, only events to deliver
+				logger.WithError(err).Debug("ignoring stale /meta/connect response")
+				err = nil
+			}
 			if err != nil {
 				logger.WithError(err).Debug("error in /meta/connect")
-				return err
-			}
-			batch := make([]Message, 0)
-			lastChannel := emptyChannel
-			logger.Debug("delivering messages")
-			for _, m := range ms {
-				switch lastChannel {
-				case emptyChannel:
-					lastChannel = m.Channel
-					batch = append(batch, m)
-				case m.Channel:
-					batch = append(batch, m)
-				default:
-					msgChan, err := c.subscriptions.Get(lastChannel)
-					if err != nil {
+				c.recordError(OperationConnect, err)
+				notifyPollWaiters(waiters, err)
+				action, badResponse, ok := c.statusAction(err)
+				if !ok {
+					if !c.connectTolerance.allow(c.clock.Now()) {
+						return err
+					}
+					logger.WithError(err).Debug("tolerating /meta/connect failure")
+					c.sendError(errors, err)
+					advice := c.client.state.GetAdvice()
+					if handshakeAdvised(ms) || advice.ShouldHandshake() {
+						nextConnect = nil
+						c.enqueueHandshakeRequest()
+						continue
+					}
+					nextConnect = c.clock.After(jitterInterval(advice.IntervalAsDuration(), c.connectJitter, rand.Float64()))
+					continue
+				}
+				if action.Recovery == FailOnStatus {
+					return err
+				}
+				if action.Hook != nil {
+					if err := action.Hook(ctx, badResponse); err != nil {
 						return err
 					}
-					logger.WithField("channel", lastChannel).Debug("sending batch")
-					msgChan <- batch
-					lastChannel = m.Channel
-					batch = append([]Message(nil), m)
 				}
+				switch action.Recovery {
+				case RetryOnStatus:
+					logger.WithField("backoff", action.Backoff).Debug("retrying /meta/connect")
+					atomic.StoreInt64(&c.backoffUntil, c.clock.Now().Add(action.Backoff).UnixNano())
+					nextConnect = c.clock.After(action.Backoff)
+				case RehandshakeOnStatus:
+					nextConnect = nil
+					c.enqueueHandshakeRequest()
+				}
+				continue
+			}
+			c.stats.observeConnect(c.clock.Now(), ms)
+			deliveryLogger := logger.WithField("clientID", c.client.state.GetClientID())
+			deliveryLogger.WithField("messages", len(ms)).Debug("delivering messages")
+			if err := c.deliver(deliveryLogger, ms); err != nil {
+				if err == ErrClientClosed {
+					return nil
+				}
+				return notifyPollWaiters(waiters, c.recordError(OperationConnect, err))
+			}
+			notifyPollWaiters(waiters, nil)
+			if !containsChannel(ms, MetaConnect) {
+				// Without a /meta/connect reply there is no advice to wait
+				// on so connect again straight away
+				c.enqueueConnectRequest()
 			}
+		}
+	}
+	return nil
+}
 
-		default:
-			c.enqueueConnectRequest()
+// deliver sends the messages for each channel in ms to its receivers. It
+// returns ErrClientClosed if the client is disconnected part way through.
+func (c *Client) deliver(logger Logger, ms []Message) error {
+	batches, channels := groupByChannel(ms)
+	for _, channel := range c.deliveryOrder(channels) {
+		receivers, err := c.subscriptions.Get(channel)
+		if err != nil {
+			return err
+		}
+		batch := batches[channel]
+		if channel.Type() != MetaChannel {
+			if c.dedupe != nil {
+				if batch = c.dedupe.filter(batch); len(batch) == 0 {
+					continue
+				}
+			}
+			c.metrics.AddMessages(len(batch))
+		}
+		batchLogger := logger.WithField("channel", channel)
+		for _, m := range batch {
+			batchLogger.WithField("id", m.ID).Debug("delivering message")
+		}
+		batchLogger.WithField("messages", len(batch)).Debug("sending batch")
+		chunks := [][]Message{batch}
+		if channel.Type() != MetaChannel {
+			chunks = splitBatch(batch, c.maxDeliveryBatch)
+		}
+		for _, chunk := range chunks {
+			// Each receiver gets its own copy to modify
+			copies := copiesFor(chunk, len(receivers))
+			for i, msgChan := range receivers {
+				select {
+				case msgChan <- copies[i]:
+				case <-c.shutdown:
+					return ErrClientClosed
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// containsChannel reports whether any message in ms is on channel
+func containsChannel(ms []Message, channel Channel) bool {
+	for _, m := range ms {
+		if m.Channel == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribe sends one /meta/subscribe request for every channel in subReqs
+// and adds the receivers of those the server accepts. It returns an error
+// only when the Client must stop.
+func (c *Client) subscribe(ctx context.Context, errors chan<- error, subReqs []subscriptionRequest) error {
+	logger := c.logger.WithField("at", "subscribe")
+	channels := make([]Channel, 0, len(subReqs))
+	requested := make(map[Channel]bool, len(subReqs))
+	for _, req := range subReqs {
+		if !requested[req.subscription] {
+			requested[req.subscription] = true
+			channels = append(channels, req.subscription)
+		}
+	}
+	response, err := c.client.Subscribe(ctx, channels)
+	if err != nil && handshakeAdvised(response) {
+		// The session expired so subscribe again in a new one
+		logger.WithError(err).Debug("re-handshaking as advised by /meta/subscribe")
+		c.recordError(OperationSubscribe, err)
+		if err := c.rehandshake(ctx, errors); err != nil {
+			return err
+		}
+		_, err = c.client.Subscribe(ctx, channels)
+	}
+	if isStaleSession(err) {
+		// The request went out in a session that has since been replaced
+		// so subscribe in the current one
+		logger.WithError(err).Debug("subscribing again in the current session")
+		_, err = c.client.Subscribe(ctx, channels)
+	}
+	notifyResults(c.onSubscribe, channels, err)
+	if err != nil {
+		c.recordError(OperationSubscribe, err)
+		if !c.canContinue(err) {
+			return err
+		}
+
+		c.sendError(errors, err)
+		// Keep the subscriptions the server did accept
+		var rejected []subscriptionRequest
+		subReqs, rejected = acceptedSubscriptions(subReqs, err)
+		for _, subReq := range rejected {
+			c.typed.refused(subReq.subscription, subReq.msgChan)
+		}
+	}
+
+	now := c.clock.Now()
+	for _, subReq := range subReqs {
+		if c.fanOut || subReq.listener {
+			c.subscriptions.AddReceiver(subReq.subscription, subReq.msgChan)
+			c.renewal.Track(subReq.subscription, now)
+			continue
+		}
+		if err := c.subscriptions.Add(subReq.subscription, subReq.msgChan); err != nil {
+			c.recordError(OperationSubscribe, err)
+			if c.canContinue(err) {
+				c.sendError(errors, err)
+				c.typed.refused(subReq.subscription, subReq.msgChan)
+				continue
+			}
+
+			return err
+		}
+		c.renewal.Track(subReq.subscription, now)
+	}
+	return nil
+}
+
+// rehandshake starts a new session after the server has discarded ours,
+// waiting for the reconnect delay first, and subscribes to every channel
+// again
+func (c *Client) rehandshake(ctx context.Context, errors chan<- error) error {
+	logger := c.logger.WithField("at", "rehandshake")
+	if c.rehandshakeNotices {
+		c.sendError(errors, RehandshakeNotice{ClientID: c.client.state.GetClientID()})
+	}
+	if c.reconnectDelay > 0 {
+		logger.WithField("delay", c.reconnectDelay).Debug("waiting before re-handshaking")
+		atomic.StoreInt64(&c.backoffUntil, c.clock.Now().Add(c.reconnectDelay).UnixNano())
+		select {
+		case <-c.clock.After(c.reconnectDelay):
+			atomic.StoreInt64(&c.backoffUntil, 0)
+		case <-ctx.Done():
+			atomic.StoreInt64(&c.backoffUntil, 0)
+			return ctx.Err()
+		}
+	}
+	logger.Debug("re-handshaking")
+	// The server has discarded our session so we start over from the
+	// unconnected state
+	_ = c.client.stateMachine.ProcessEvent(timeout)
+	c.metrics.IncReconnect()
+	if _, err := c.client.Handshake(ctx); err != nil {
+		return c.recordError(OperationHandshake, err)
+	}
+	return c.resubscribe(ctx, errors)
+}
+
+// handshakeAdvised reports whether any reply in ms advises the client to
+// handshake again, e.g., because its session expired
+func handshakeAdvised(ms []Message) bool {
+	for _, m := range ms {
+		if m.Channel.Type() == MetaChannel && m.Advice.ShouldHandshake() {
+			return true
 		}
 	}
+	return false
+}
+
+// resubscribe subscribes again to every channel after a re-handshake since
+// the server forgets the subscriptions of the session it discarded
+func (c *Client) resubscribe(ctx context.Context, errors chan<- error) error {
+	channels := c.subscriptions.List()
+	if len(channels) == 0 {
+		return nil
+	}
+	c.logger.WithField("at", "resubscribe").WithField("channels", channels).Debug("resubscribing after re-handshake")
+	if _, err := c.client.Subscribe(ctx, channels); err != nil {
+		if isStaleSession(err) {
+			// Whatever replaced the session subscribes in the new one
+			c.logger.WithField("at", "resubscribe").WithError(err).Debug("ignoring response for replaced session")
+			return nil
+		}
+		c.recordError(OperationSubscribe, err)
+		if !c.canContinue(err) {
+			return err
+		}
+
+		c.sendError(errors, err)
+		channels = acceptedChannels(err)
+	}
+	now := c.clock.Now()
+	for _, channel := range channels {
+		c.renewal.Track(channel, now)
+	}
 	return nil
 }
 
-func (c *Client) getSubscriptionRequests() ([]subscriptionRequest, []Channel) {
+func (c *Client) getSubscriptionRequests() []subscriptionRequest {
 	subscriptionRequests := make([]subscriptionRequest, 0)
-	channels := make([]Channel, 0)
 
 _get_subs_for_loop:
 	for {
 		select {
-		case req := <-c.subscribeRequestChannel:
-			subscriptionRequests = append(subscriptionRequests, req)
-			channels = append(channels, req.subscription)
+		case reqs := <-c.subscribeRequestChannel:
+			subscriptionRequests = append(subscriptionRequests, reqs...)
 		default:
 			break _get_subs_for_loop
 		}
 	}
-	return subscriptionRequests, channels
+	return subscriptionRequests
+}
+
+// canContinue reports whether the Client keeps running after a subscribe or
+// unsubscribe error
+func (c *Client) canContinue(err error) bool {
+	return c.ignoreError(err) || (c.continueOnError && isNonFatal(err))
+}
+
+// isStale reports whether err is for a response that answered an earlier
+// request or session and so can be ignored
+func isStale(err error) bool {
+	return errors.Is(err, ErrStaleConnect) || isStaleSession(err)
+}
+
+// isStaleSession reports whether err is for a response to a request sent
+// in a session that has since been replaced
+func isStaleSession(err error) bool {
+	return errors.Is(err, ErrStaleSession)
+}
+
+func (c *Client) recordError(operation string, err error) error {
+	c.lastErrors.Set(operation, err)
+	return err
 }
 
 func (c *Client) enqueueConnectRequest() {
@@ -336,14 +1508,24 @@ func (c *Client) enqueueConnectRequest() {
 	}
 }
 
+func (c *Client) enqueueHandshakeRequest() {
+	logger := c.logger.WithField("at", "enqueueHandshakeRequest")
+	select {
+	case c.handshakeRequestChannel <- struct{}{}:
+		logger.Debug("queued new handshake request")
+	default:
+		logger.Debug("handshake request already queued")
+	}
+}
+
 func (c *Client) getUnsubscriptionRequests() []Channel {
 	unsubscriptionRequests := make([]Channel, 0)
 
 _get_unsubs_for_loop:
 	for {
 		select {
-		case req := <-c.unsubscribeRequestChannel:
-			unsubscriptionRequests = append(unsubscriptionRequests, req)
+		case reqs := <-c.unsubscribeRequestChannel:
+			unsubscriptionRequests = append(unsubscriptionRequests, reqs...)
 		default:
 			break _get_unsubs_for_loop
 		}
@@ -351,7 +1533,80 @@ _get_unsubs_for_loop:
 	return unsubscriptionRequests
 }
 
+// acceptedChannels returns the channels the server accepted when a batched
+// subscribe request returned err
+func acceptedChannels(err error) []Channel {
+	var subErr SubscriptionFailedError
+	if !errors.As(err, &subErr) {
+		return nil
+	}
+	return subErr.Succeeded()
+}
+
+// notifyResults calls f, if there is one, with the result for each of the
+// channels in a request which returned err. A SubscriptionFailedError which
+// lists the channels that failed only gives those channels an error.
+func notifyResults(f SubscriptionResultFunc, channels []Channel, err error) {
+	if f == nil {
+		return
+	}
+	var subErr SubscriptionFailedError
+	partial := errors.As(err, &subErr) && subErr.Failed != nil
+	for _, channel := range channels {
+		if partial {
+			f(channel, subErr.Failed[channel])
+			continue
+		}
+		f(channel, err)
+	}
+}
+
+// acceptedSubscriptions splits subReqs into those the server accepted and
+// those it rejected when a batched subscribe request returned err
+func acceptedSubscriptions(subReqs []subscriptionRequest, err error) (accepted, rejected []subscriptionRequest) {
+	ok := make(map[Channel]bool)
+	for _, channel := range acceptedChannels(err) {
+		ok[channel] = true
+	}
+	for _, subReq := range subReqs {
+		if ok[subReq.subscription] {
+			accepted = append(accepted, subReq)
+			continue
+		}
+		rejected = append(rejected, subReq)
+	}
+	return accepted, rejected
+}
+
 type subscriptionRequest struct {
 	subscription Channel
 	msgChan      chan []Message
+	// listener requests come from AddListener and may share the channel
+	// with other receivers
+	listener bool
+}
+
+type lastErrors struct {
+	lock sync.RWMutex
+	errs map[string]error
+}
+
+func newLastErrors() *lastErrors {
+	return &lastErrors{errs: make(map[string]error)}
+}
+
+func (le *lastErrors) Set(operation string, err error) {
+	le.lock.Lock()
+	defer le.lock.Unlock()
+	le.errs[operation] = err
+}
+
+func (le *lastErrors) Snapshot() map[string]error {
+	le.lock.RLock()
+	defer le.lock.RUnlock()
+	errs := make(map[string]error, len(le.errs))
+	for operation, err := range le.errs {
+		errs[operation] = err
+	}
+	return errs
 }
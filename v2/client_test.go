@@ -1,15 +1,26 @@
 package gobayeux_test
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/sigmavirus24/gobayeux/v2"
-	"github.com/sigmavirus24/gobayeux/v2/internal/gobayeuxtest"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+	"go.uber.org/goleak"
 )
 
 func TestNewClient(t *testing.T) {
@@ -20,6 +31,9 @@ func TestNewClient(t *testing.T) {
 	}{
 		{"valid url for server address", "https://example.com", false},
 		{"invalid url for server address", "http://192.168.0.%31/", true},
+		{"websocket url for server address", "wss://example.com", false},
+		{"unsupported scheme for server address", "ftp://example.com", true},
+		{"missing scheme for server address", "example.com/cometd", true},
 	}
 
 	for _, testCase := range testCases {
@@ -35,6 +49,49 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestNewClientConvertsWebsocketSchemes(t *testing.T) {
+	testCases := []struct {
+		serverAddress string
+		want          string
+	}{
+		{"ws://example.com/cometd", "http"},
+		{"wss://example.com/cometd", "https"},
+		{"HTTPS://example.com/cometd", "https"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.serverAddress, func(t *testing.T) {
+			var got string
+			transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+				got = r.URL.Scheme
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(strings.NewReader(`[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`)),
+				}, nil
+			})
+
+			client, err := gobayeux.NewBayeuxClient(nil, transport, tc.serverAddress, nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			if _, err := client.Handshake(context.Background()); err != nil {
+				t.Fatalf("failed to handshake (%v)", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected the request to use %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	_, err := gobayeux.NewClient("ftp://example.com")
+	var schemeErr gobayeux.BadSchemeError
+	if !errors.As(err, &schemeErr) || schemeErr.Scheme != "ftp" {
+		t.Errorf("expected a BadSchemeError for ftp, got %v", err)
+	}
+}
+
 func TestSubscribe(t *testing.T) {
 	client, err := gobayeux.NewClient("https://example.com", nil)
 	if err != nil {
@@ -136,7 +193,7 @@ func TestCanDoubleSubscribe(t *testing.T) {
 	client, err := gobayeux.NewClient(
 		"https://example.com",
 		gobayeux.WithHTTPTransport(server),
-		gobayeux.WithIgnoreError(func(err error) bool { return true }),
+		gobayeux.WithContinueOnError(true),
 	)
 
 	if err != nil {
@@ -159,7 +216,7 @@ func TestCanDoubleSubscribe(t *testing.T) {
 			case ms := <-msgs:
 				count += len(ms)
 			case err := <-errs:
-				if !strings.Contains(err.Error(), "already subscribed") {
+				if !errors.Is(err, gobayeux.ErrAlreadySubscribed) {
 					done <- err
 					return
 				}
@@ -189,3 +246,2483 @@ func TestCanDoubleSubscribe(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestLastErrors(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithIgnoreError(func(err error) bool { return true }),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	if errs := client.LastErrors(); len(errs) != 0 {
+		t.Fatalf("expected no errors before starting, got %v", errs)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	msgs := make(chan []gobayeux.Message, 100)
+	client.Subscribe("/foo/bar", msgs)
+	client.Subscribe("/foo/bar", msgs)
+
+	select {
+	case err := <-errs:
+		got := client.LastErrors()[gobayeux.OperationSubscribe]
+		if got == nil || got.Error() != err.Error() {
+			t.Errorf("expected last subscribe error to be %v, got %v", err, got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("test timed out")
+	}
+
+	if got := client.LastErrors()[gobayeux.OperationHandshake]; got != nil {
+		t.Errorf("expected no handshake error, got %v", got)
+	}
+}
+
+func TestBayeuxClientSubscribeReportsFailedChannels(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.Deny("/foo/denied")
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if _, err := client.Handshake(context.Background()); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	channels := []gobayeux.Channel{"/foo/bar", "/foo/denied", "/foo/baz"}
+	_, err = client.Subscribe(context.Background(), channels)
+
+	var subErr gobayeux.SubscriptionFailedError
+	if !errors.As(err, &subErr) {
+		t.Fatalf("expected a SubscriptionFailedError, got %v", err)
+	}
+	if len(subErr.Failed) != 1 {
+		t.Fatalf("expected exactly one failed channel, got %v", subErr.Failed)
+	}
+	if _, ok := subErr.Failed["/foo/denied"]; !ok {
+		t.Errorf("expected /foo/denied to be reported as failed, got %v", subErr.Failed)
+	}
+
+	succeeded := subErr.Succeeded()
+	if len(succeeded) != 2 || succeeded[0] != "/foo/bar" || succeeded[1] != "/foo/baz" {
+		t.Errorf("expected the other channels to succeed, got %v", succeeded)
+	}
+}
+
+func TestWithCrossChannelDeliveryOrder(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithCrossChannelDeliveryOrder(gobayeux.PriorityDeliveryOrder("/foo/b")),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	// Both subscriptions are queued before starting so they are sent in the
+	// same request and their events arrive in the same connect response
+	msgs := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/a", msgs)
+	client.Subscribe("/foo/b", msgs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	for _, want := range []gobayeux.Channel{"/foo/b", "/foo/a"} {
+		select {
+		case ms := <-msgs:
+			if got := ms[0].Channel; got != want {
+				t.Fatalf("expected a batch for %s, got %s", want, got)
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("test timed out")
+		}
+	}
+}
+
+func TestInterleavedChannelsAreGrouped(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	// Replace the first events the server generates with events for both
+	// channels interleaved in one response
+	var injected int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		resp, err := server.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			return nil, err
+		}
+		replies := make([]gobayeux.Message, 0, len(ms))
+		for _, m := range ms {
+			if m.Channel.Type() == gobayeux.MetaChannel {
+				replies = append(replies, m)
+			}
+		}
+		if len(replies) < len(ms) && atomic.CompareAndSwapInt32(&injected, 0, 1) {
+			for i, channel := range []gobayeux.Channel{"/foo/a", "/foo/b", "/foo/a", "/foo/b", "/foo/a"} {
+				replies = append(replies, gobayeux.Message{Channel: channel, ID: fmt.Sprint(i + 1), Data: json.RawMessage(`{}`)})
+			}
+		} else {
+			replies = ms
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	aMsgs := make(chan []gobayeux.Message, 10)
+	bMsgs := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/a", aMsgs)
+	client.Subscribe("/foo/b", bMsgs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	for _, tc := range []struct {
+		msgs chan []gobayeux.Message
+		want []string
+	}{
+		{aMsgs, []string{"1", "3", "5"}},
+		{bMsgs, []string{"2", "4"}},
+	} {
+		select {
+		case ms := <-tc.msgs:
+			ids := make([]string, 0, len(ms))
+			for _, m := range ms {
+				ids = append(ids, m.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tc.want) {
+				t.Errorf("expected one batch with messages %v, got %v", tc.want, ids)
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("test timed out")
+		}
+	}
+}
+
+func TestSubscribeMany(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	var subscribeRequests [][]gobayeux.Channel
+	var lock sync.Mutex
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.Unmarshal(body, &ms); err != nil {
+			return nil, err
+		}
+		var channels []gobayeux.Channel
+		for _, m := range ms {
+			if m.Channel == gobayeux.MetaSubscribe {
+				channels = append(channels, m.Subscription)
+			}
+		}
+		if len(channels) > 0 {
+			lock.Lock()
+			subscribeRequests = append(subscribeRequests, channels)
+			lock.Unlock()
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	channels := []gobayeux.Channel{"/foo/a", "/foo/b", "/foo/c", "/foo/d", "/foo/e"}
+	msgs := make(chan []gobayeux.Message, 10)
+	if err := client.SubscribeMany(channels, msgs); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+
+	seen := make(map[gobayeux.Channel]bool)
+	for len(seen) < len(channels) {
+		select {
+		case ms := <-msgs:
+			seen[ms[0].Channel] = true
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for messages, saw %v", seen)
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(subscribeRequests) != 1 || len(subscribeRequests[0]) != len(channels) {
+		t.Errorf("expected one subscribe request for %v, got %v", channels, subscribeRequests)
+	}
+
+	var emptyErr gobayeux.EmptySliceError
+	if err := client.SubscribeMany(nil, msgs); !errors.As(err, &emptyErr) {
+		t.Errorf("expected an EmptySliceError without channels, got %v", err)
+	}
+}
+
+func TestWithSubscriptionRenewal(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	var subscribes int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(body, []byte(gobayeux.MetaSubscribe)) {
+			atomic.AddInt32(&subscribes, 1)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(transport),
+		gobayeux.WithSubscriptionRenewal(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	msgs := make(chan []gobayeux.Message)
+	client.Subscribe("/foo/bar", msgs)
+
+	timeout := time.After(5 * time.Second)
+	for atomic.LoadInt32(&subscribes) < 3 {
+		select {
+		case <-msgs:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-timeout:
+			t.Fatalf("expected subscription to be renewed, saw %d subscribe requests", atomic.LoadInt32(&subscribes))
+		}
+	}
+}
+
+func TestResubscribeAfterRehandshake(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	// Advise a re-handshake the first time events are delivered, after
+	// which the server has forgotten the subscription. As a server that
+	// dropped the session would, keep advising it until the next handshake
+	// since a later reply's advice replaces it.
+	var handshakes, advised int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		resp, err := server.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			return nil, err
+		}
+		events := false
+		for _, m := range ms {
+			if m.Channel == gobayeux.MetaHandshake {
+				atomic.AddInt32(&handshakes, 1)
+			}
+			events = events || m.Channel.Type() != gobayeux.MetaChannel
+		}
+		if events {
+			atomic.CompareAndSwapInt32(&advised, 0, 1)
+		}
+		if atomic.LoadInt32(&advised) == 1 && atomic.LoadInt32(&handshakes) == 1 {
+			for i := range ms {
+				if ms[i].Channel == gobayeux.MetaConnect {
+					ms[i].Advice = &gobayeux.Advice{Reconnect: "handshake"}
+				}
+			}
+		}
+		body, err := json.Marshal(ms)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	msgs := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/bar", msgs)
+
+	afterRehandshake := 0
+	for afterRehandshake < 2 {
+		select {
+		case <-msgs:
+			if atomic.LoadInt32(&handshakes) > 1 {
+				afterRehandshake++
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for messages after %d handshakes", atomic.LoadInt32(&handshakes))
+		}
+	}
+
+	if subs := server.Subscriptions(client.SessionInfo().ClientID); len(subs) != 1 || subs[0] != "/foo/bar" {
+		t.Errorf("expected the new session to be subscribed to /foo/bar, got %v", subs)
+	}
+}
+
+func TestSubscribeRehandshakesWhenAdvised(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	// Reject the first subscribe as if the session had expired
+	var handshakes, rejected int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		var requests []gobayeux.Message
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, err
+		}
+		switch requests[0].Channel {
+		case gobayeux.MetaHandshake:
+			atomic.AddInt32(&handshakes, 1)
+		case gobayeux.MetaSubscribe:
+			if atomic.CompareAndSwapInt32(&rejected, 0, 1) {
+				replies := make([]gobayeux.Message, 0, len(requests))
+				for _, m := range requests {
+					replies = append(replies, gobayeux.Message{
+						Channel:      gobayeux.MetaSubscribe,
+						ID:           m.ID,
+						Subscription: m.Subscription,
+						Error:        "402::Unknown client",
+						Advice:       &gobayeux.Advice{Reconnect: "handshake"},
+					})
+				}
+				reply, err := json.Marshal(replies)
+				if err != nil {
+					return nil, err
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(bytes.NewReader(reply)),
+				}, nil
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	msgs := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/bar", msgs)
+
+	select {
+	case ms := <-msgs:
+		if ms[0].Channel != "/foo/bar" {
+			t.Errorf("expected messages on /foo/bar, got %+v", ms)
+		}
+	case err := <-errs:
+		t.Fatalf("expected the client to recover, got %v", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for messages")
+	}
+	if got := atomic.LoadInt32(&handshakes); got != 2 {
+		t.Errorf("expected the client to re-handshake once, saw %d handshakes", got)
+	}
+	if subs := server.Subscriptions(client.ClientID()); len(subs) != 1 || subs[0] != "/foo/bar" {
+		t.Errorf("expected the new session to be subscribed to /foo/bar, got %v", subs)
+	}
+}
+
+func TestMessageRawPreservesUnknownFields(t *testing.T) {
+	handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body := `[{"channel":"/meta/handshake","clientId":"abc","successful":true,"vendorField":{"region":"eu"}}]`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+		}, nil
+	})
+
+	client, err := gobayeux.NewBayeuxClient(nil, handler, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ms, err := client.Handshake(context.Background())
+	if err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(ms[0].Raw, &raw); err != nil {
+		t.Fatalf("expected Raw to hold valid JSON, got %q (%v)", ms[0].Raw, err)
+	}
+	if got, want := string(raw["vendorField"]), `{"region":"eu"}`; got != want {
+		t.Errorf("expected vendorField %s in Raw, got %s", want, got)
+	}
+
+	encoded, err := json.Marshal(ms[0])
+	if err != nil {
+		t.Fatalf("failed to marshal message (%v)", err)
+	}
+	if bytes.Contains(encoded, []byte("vendorField")) {
+		t.Errorf("expected Raw to not be sent with the message, got %s", encoded)
+	}
+}
+
+func TestWithUserAgent(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []gobayeux.Option
+		want string
+	}{
+		{"default user agent", nil, gobayeux.DefaultUserAgent},
+		{"custom user agent", []gobayeux.Option{gobayeux.WithUserAgent("my-app/1.0")}, "my-app/1.0"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
+				got = r.Header.Get("User-Agent")
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(bytes.NewBufferString(`[]`)),
+				}, nil
+			})
+
+			client, err := gobayeux.NewBayeuxClient(nil, handler, "https://example.com", nil, tc.opts...)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			_, _ = client.Handshake(context.Background())
+
+			if got != tc.want {
+				t.Errorf("expected User-Agent %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestStateReportsBackoff(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetAdvice(gobayeux.Advice{Reconnect: "handshake"})
+
+	// This seed results in a delay of just under two seconds
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithReconnectSeed(1),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	if state := client.State().State; state != "UNCONNECTED" {
+		t.Errorf("expected client to start unconnected, got %s", state)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-timeout:
+			t.Fatalf("expected client to back off, last state %+v", client.State())
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		status := client.State()
+		if status.State != gobayeux.BackoffState {
+			continue
+		}
+		if status.Remaining <= 0 || status.Remaining > 5*time.Second {
+			t.Errorf("expected remaining backoff within (0s, 5s], got %s", status.Remaining)
+		}
+		return
+	}
+}
+
+func TestConnectIntervalIsCoalesced(t *testing.T) {
+	var connects int32
+	handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		reply := `[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`
+		if bytes.Contains(body, []byte(gobayeux.MetaConnect)) {
+			reply = `[{"channel":"/meta/connect","successful":true,"advice":{"reconnect":"retry","interval":10000}}]`
+			if atomic.AddInt32(&connects, 1) == 1 {
+				// Five replies each advising a short interval should only
+				// result in a single follow-up connect
+				advised := `{"channel":"/meta/connect","successful":true,"advice":{"reconnect":"retry","interval":50}}`
+				reply = "[" + strings.Repeat(advised+",", 4) + advised + "]"
+			}
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewBufferString(reply)),
+		}, nil
+	})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(handler))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	select {
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	if got := atomic.LoadInt32(&connects); got != 2 {
+		t.Errorf("expected exactly 2 connect requests, got %d", got)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	var connects int32
+	handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		reply := `[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`
+		if bytes.Contains(body, []byte(gobayeux.MetaConnect)) {
+			atomic.AddInt32(&connects, 1)
+			// Without Poll the client would wait an hour to connect again
+			reply = `[{"channel":"/meta/connect","successful":true,"advice":{"reconnect":"retry","interval":3600000}}]`
+		}
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewBufferString(reply)),
+		}, nil
+	})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(handler))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		before := atomic.LoadInt32(&connects)
+		if err := client.Poll(ctx); err != nil {
+			t.Fatalf("failed to poll (%v)", err)
+		}
+		if got := atomic.LoadInt32(&connects); got <= before {
+			t.Fatalf("expected Poll to connect, saw %d connects before and %d after", before, got)
+		}
+	}
+
+	if err := client.Disconnect(ctx); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+	if err := client.Poll(ctx); !errors.Is(err, gobayeux.ErrClientClosed) {
+		t.Errorf("expected ErrClientClosed after Disconnect, got %v", err)
+	}
+}
+
+func gzipBody(t *testing.T, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(body)); err != nil {
+		t.Fatalf("failed to compress body (%v)", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("failed to compress body (%v)", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGzipResponses(t *testing.T) {
+	const handshake = `[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`
+
+	t.Run("http.Transport negotiates and decompresses", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				t.Errorf("expected gzip to be accepted, got %q", r.Header.Get("Accept-Encoding"))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(gzipBody(t, handshake))
+		}))
+		defer server.Close()
+
+		client, err := gobayeux.NewBayeuxClient(nil, nil, server.URL, nil)
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		ms, err := client.Handshake(context.Background())
+		if err != nil {
+			t.Fatalf("failed to handshake (%v)", err)
+		}
+		if len(ms) != 1 || ms[0].ClientID != "abc" {
+			t.Errorf("unexpected handshake response %v", ms)
+		}
+	})
+
+	t.Run("compressed body from a RoundTripper is decompressed", func(t *testing.T) {
+		transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+			header := make(http.Header)
+			header.Set("Content-Encoding", "gzip")
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Status:     http.StatusText(http.StatusOK),
+				Header:     header,
+				Body:       io.NopCloser(bytes.NewReader(gzipBody(t, handshake))),
+			}, nil
+		})
+
+		client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil)
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		ms, err := client.Handshake(context.Background())
+		if err != nil {
+			t.Fatalf("failed to handshake (%v)", err)
+		}
+		if len(ms) != 1 || ms[0].ClientID != "abc" {
+			t.Errorf("unexpected handshake response %v", ms)
+		}
+	})
+}
+
+func TestWithMaxResponseBytes(t *testing.T) {
+	const handshake = `[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`
+	testCases := []struct {
+		name      string
+		limit     int64
+		shouldErr bool
+	}{
+		{"no limit", 0, false},
+		{"limit of exactly the body", int64(len(handshake)), false},
+		{"limit below the body", int64(len(handshake)) - 1, true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(bytes.NewBufferString(handshake)),
+				}, nil
+			})
+			client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil, gobayeux.WithMaxResponseBytes(tc.limit))
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			_, err = client.Handshake(context.Background())
+			var tooLarge gobayeux.ResponseTooLargeError
+			if got := errors.As(err, &tooLarge); got != tc.shouldErr {
+				t.Fatalf("expected a ResponseTooLargeError: %v, got %v", tc.shouldErr, err)
+			}
+			if tc.shouldErr && (tooLarge.Limit != tc.limit || tooLarge.Operation != gobayeux.OperationHandshake) {
+				t.Errorf("unexpected ResponseTooLargeError %+v", tooLarge)
+			}
+		})
+	}
+}
+
+func TestConnectAcceptsEmptyResponses(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+	}{
+		{"204 no content", http.StatusNoContent},
+		{"200 with empty body", http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					return nil, err
+				}
+
+				if bytes.Contains(body, []byte(gobayeux.MetaHandshake)) {
+					return &http.Response{
+						StatusCode: http.StatusOK,
+						Status:     http.StatusText(http.StatusOK),
+						Body:       io.NopCloser(bytes.NewBufferString(`[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`)),
+					}, nil
+				}
+
+				return &http.Response{
+					StatusCode: tc.statusCode,
+					Status:     http.StatusText(tc.statusCode),
+					Body:       http.NoBody,
+				}, nil
+			})
+
+			client, err := gobayeux.NewBayeuxClient(nil, handler, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			if _, err := client.Handshake(context.Background()); err != nil {
+				t.Fatalf("failed to handshake (%v)", err)
+			}
+
+			ms, err := client.Connect(context.Background())
+			if err != nil {
+				t.Fatalf("expected an empty response to be valid, got %v", err)
+			}
+			if len(ms) != 0 {
+				t.Errorf("expected no messages, got %v", ms)
+			}
+		})
+	}
+}
+
+func TestWithHandshakeExt(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithHandshakeExt(map[string]interface{}{"authentication": map[string]interface{}{"token": "abc"}}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	auth, _ := server.LastExt(gobayeux.MetaHandshake)["authentication"].(map[string]interface{})
+	if auth["token"] != "abc" {
+		t.Errorf("expected the handshake ext to carry the token, got %v", server.LastExt(gobayeux.MetaHandshake))
+	}
+}
+
+func TestWithBayeuxVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		opts        []gobayeux.Option
+		version     string
+		minVersion  string
+		expectedErr bool
+	}{
+		{"defaults", nil, "1.0", "1.0", false},
+		{"version only", []gobayeux.Option{gobayeux.WithBayeuxVersion("1.1")}, "1.1", "1.1", false},
+		{
+			"version and minimum",
+			[]gobayeux.Option{gobayeux.WithBayeuxVersion("1.1"), gobayeux.WithMinimumBayeuxVersion("1.0")},
+			"1.1", "1.0", false,
+		},
+		{"invalid version", []gobayeux.Option{gobayeux.WithBayeuxVersion("one")}, "", "", true},
+		{"invalid minimum", []gobayeux.Option{gobayeux.WithMinimumBayeuxVersion(".0")}, "", "", true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			var handshake gobayeux.Message
+			transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+				var ms []gobayeux.Message
+				if err := json.NewDecoder(r.Body).Decode(&ms); err != nil {
+					return nil, err
+				}
+				handshake = ms[0]
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(strings.NewReader(`[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`)),
+				}, nil
+			})
+
+			client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil, tc.opts...)
+			if tc.expectedErr {
+				var versionErr gobayeux.BadConnectionVersionError
+				if !errors.As(err, &versionErr) {
+					t.Errorf("expected a BadConnectionVersionError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			if _, err := client.Handshake(context.Background()); err != nil {
+				t.Fatalf("failed to handshake (%v)", err)
+			}
+			if handshake.Version != tc.version || handshake.MinimumVersion != tc.minVersion {
+				t.Errorf("expected version %q and minimumVersion %q, got %q and %q", tc.version, tc.minVersion, handshake.Version, handshake.MinimumVersion)
+			}
+		})
+	}
+}
+
+func TestMessageIDsIncrement(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	var sent, echoed []string
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		var requests []gobayeux.Message
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, err
+		}
+		for _, m := range requests {
+			sent = append(sent, m.ID)
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		resp, err := server.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		var replies []gobayeux.Message
+		if err := json.NewDecoder(resp.Body).Decode(&replies); err != nil {
+			return nil, err
+		}
+		for _, m := range replies {
+			if m.Channel.Type() == gobayeux.MetaChannel {
+				echoed = append(echoed, m.ID)
+			}
+		}
+		body, err = json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	})
+
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/a", "/foo/b"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := client.Connect(ctx); err != nil {
+			t.Fatalf("failed to connect (%v)", err)
+		}
+	}
+
+	want := []string{"1", "2", "3", "4", "5"}
+	if fmt.Sprint(sent) != fmt.Sprint(want) {
+		t.Errorf("expected message ids %v, got %v", want, sent)
+	}
+	if fmt.Sprint(echoed) != fmt.Sprint(want) {
+		t.Errorf("expected the replies to echo ids %v, got %v", want, echoed)
+	}
+}
+
+func TestExtRoundTrip(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	replay := gobayeuxtest.NewReplayExtension()
+	if err := client.UseExtension(replay); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if requested, _ := server.LastExt(gobayeux.MetaHandshake)[gobayeuxtest.ReplayExtensionName].(bool); !requested {
+		t.Errorf("expected the handshake ext to request replay, got %v", server.LastExt(gobayeux.MetaHandshake))
+	}
+	if !replay.Supported() {
+		t.Error("expected the handshake reply ext to enable replay")
+	}
+
+	const channel gobayeux.Channel = "/foo/bar"
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{channel}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	ms, err := client.Connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+
+	var delivered *gobayeux.Message
+	for i := range ms {
+		if ms[i].Channel == channel {
+			delivered = &ms[i]
+		}
+	}
+	if delivered == nil {
+		t.Fatalf("expected a message on %s, got %v", channel, ms)
+	}
+	if id, _ := delivered.Ext[gobayeuxtest.ReplayExtensionName].(float64); id != 1 {
+		t.Errorf("expected the delivered message to carry replay id 1, got %v", delivered.Ext)
+	}
+	if id, ok := replay.LastID(channel); !ok || id != 1 {
+		t.Errorf("expected the extension to record replay id 1, got %d", id)
+	}
+
+	if _, err := client.Unsubscribe(ctx, []gobayeux.Channel{channel}); err != nil {
+		t.Fatalf("failed to unsubscribe (%v)", err)
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{channel}); err != nil {
+		t.Fatalf("failed to resubscribe (%v)", err)
+	}
+	if id, _ := server.LastExt(gobayeux.MetaSubscribe)[gobayeuxtest.ReplayExtensionName].(float64); id != 1 {
+		t.Errorf("expected the subscribe ext to carry replay id 1, got %v", server.LastExt(gobayeux.MetaSubscribe))
+	}
+}
+
+func TestWithACLProbe(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.Deny("/foo/denied")
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil,
+		gobayeux.WithACLProbe(gobayeuxtest.ACLProbeChannel),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if _, err := client.Handshake(context.Background()); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	channels := []gobayeux.Channel{"/foo/bar", "/foo/denied"}
+	_, err = client.Subscribe(context.Background(), channels)
+
+	var authErr gobayeux.AuthorizationError
+	if !errors.As(err, &authErr) {
+		t.Fatalf("expected an AuthorizationError, got %v", err)
+	}
+	if authErr.Channel != "/foo/denied" {
+		t.Errorf("expected /foo/denied to be unauthorized, got %q", authErr.Channel)
+	}
+
+	var subErr gobayeux.SubscriptionFailedError
+	if !errors.As(err, &subErr) {
+		t.Fatalf("expected a SubscriptionFailedError, got %v", err)
+	}
+	if succeeded := subErr.Succeeded(); len(succeeded) != 1 || succeeded[0] != "/foo/bar" {
+		t.Errorf("expected only /foo/bar to be subscribed, got %v", succeeded)
+	}
+
+	_, err = client.Subscribe(context.Background(), []gobayeux.Channel{"/foo/denied"})
+	if !errors.As(err, &authErr) {
+		t.Errorf("expected an AuthorizationError when every channel is denied, got %v", err)
+	}
+}
+
+func TestWithACLProbeRequiresServiceChannel(t *testing.T) {
+	_, err := gobayeux.NewBayeuxClient(nil, nil, "https://example.com", nil,
+		gobayeux.WithACLProbe("/foo/acl"),
+	)
+
+	var channelErr gobayeux.InvalidChannelError
+	if !errors.As(err, &channelErr) {
+		t.Errorf("expected an InvalidChannelError, got %v", err)
+	}
+}
+
+func TestHandshakeValidatesSupportedConnectionTypes(t *testing.T) {
+	testCases := []struct {
+		name      string
+		supported string
+		wantErr   bool
+	}{
+		{"long-polling only", `["long-polling"]`, false},
+		{"long-polling among others", `["websocket","long-polling"]`, false},
+		{"no connection types listed", `null`, false},
+		{"long-polling missing", `["websocket","callback-polling"]`, true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
+				reply := `[{"channel":"/meta/handshake","clientId":"abc","successful":true,"supportedConnectionTypes":` + tc.supported + `}]`
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(bytes.NewBufferString(reply)),
+				}, nil
+			})
+
+			client, err := gobayeux.NewBayeuxClient(nil, handler, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			_, err = client.Handshake(context.Background())
+			var unsupported gobayeux.UnsupportedConnectionTypeError
+			if got := errors.As(err, &unsupported); got != tc.wantErr {
+				t.Fatalf("expected UnsupportedConnectionTypeError = %v, got %v", tc.wantErr, err)
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+			if tc.wantErr && unsupported.ConnectionType != gobayeux.ConnectionTypeLongPolling {
+				t.Errorf("expected long-polling to be reported unsupported, got %q", unsupported.ConnectionType)
+			}
+		})
+	}
+}
+
+func TestUseExtensionWhileRunning(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	msgs := make(chan []gobayeux.Message, 100)
+	client.Subscribe("/foo/bar", msgs)
+
+	// Extensions are registered while the polling loop runs them; the race
+	// detector flags any unsynchronised access
+	for i := 0; i < 20; i++ {
+		if err := client.UseExtension(gobayeuxtest.NewReplayExtension()); err != nil {
+			t.Fatalf("failed to register extension (%v)", err)
+		}
+		select {
+		case <-msgs:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+}
+
+func TestWithHTTPClientKeepsTransport(t *testing.T) {
+	var used int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		atomic.AddInt32(&used, 1)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewBufferString(`[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`)),
+		}, nil
+	})
+	httpClient := &http.Client{Transport: transport}
+
+	client, err := gobayeux.NewBayeuxClient(httpClient, nil, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if _, err := client.Handshake(context.Background()); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	if got := atomic.LoadInt32(&used); got != 1 {
+		t.Errorf("expected the client's own transport to be used, got %d requests", got)
+	}
+	if _, ok := httpClient.Transport.(roundTripFn); !ok {
+		t.Errorf("expected the http.Client to be left untouched, got transport %T", httpClient.Transport)
+	}
+}
+
+func TestSubscribeToMetaConnect(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Interval: 10})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	first := make(chan []gobayeux.Message, 10)
+	second := make(chan []gobayeux.Message, 10)
+	client.Subscribe(gobayeux.MetaConnect, first)
+	client.Subscribe(gobayeux.MetaConnect, second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	// Receiving more than one batch means the client kept following the
+	// advice itself as well
+	for _, receiver := range []chan []gobayeux.Message{first, second, first, second} {
+		select {
+		case ms := <-receiver:
+			if len(ms) != 1 || ms[0].Channel != gobayeux.MetaConnect || ms[0].Advice == nil {
+				t.Fatalf("expected a /meta/connect reply with advice, got %+v", ms)
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for /meta/connect replies")
+		}
+	}
+
+	client.Unsubscribe(gobayeux.MetaConnect)
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+}
+
+func TestWithFanOut(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithFanOut(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	first := make(chan []gobayeux.Message, 10)
+	second := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/bar", first)
+	client.Subscribe("/foo/bar", second)
+
+	for _, consumer := range []chan []gobayeux.Message{first, second, first, second} {
+		select {
+		case ms := <-consumer:
+			if len(ms) == 0 || ms[0].Channel != "/foo/bar" {
+				t.Fatalf("expected messages on /foo/bar, got %+v", ms)
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+}
+
+func TestFanOutReceiversMayModifyTheirBatch(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithFanOut(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	first := make(chan []gobayeux.Message, 10)
+	second := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/bar", first)
+	client.Subscribe("/foo/bar", second)
+
+	// Run with -race: the first receiver overwrites its batch while the
+	// client may still be sending to the second
+	for _, consumer := range []chan []gobayeux.Message{first, second, first, second} {
+		select {
+		case ms := <-consumer:
+			if len(ms) == 0 || ms[0].Channel != "/foo/bar" {
+				t.Fatalf("expected messages on /foo/bar, got %+v", ms)
+			}
+			for i := range ms {
+				ms[i] = gobayeux.Message{Channel: "/modified"}
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+}
+
+func TestAddListener(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+
+	// second is never read from so that its length exactly counts what was
+	// delivered to it
+	first := make(chan []gobayeux.Message)
+	second := make(chan []gobayeux.Message, 100)
+	var received int32
+	go func() {
+		for {
+			select {
+			case <-first:
+				atomic.AddInt32(&received, 1)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	waitFor := func(what string, done func() bool) {
+		for !done() {
+			select {
+			case err := <-errs:
+				t.Fatalf("unexpected error from client (%v)", err)
+			case <-ctx.Done():
+				t.Fatalf("timed out waiting for %s", what)
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}
+
+	client.Subscribe("/foo/bar", first)
+	if err := client.AddListener("/foo/bar", second); err != nil {
+		t.Fatalf("failed to add listener (%v)", err)
+	}
+	waitFor("both listeners to receive", func() bool {
+		return atomic.LoadInt32(&received) >= 2 && len(second) >= 2
+	})
+
+	if err := client.RemoveListener("/foo/bar", second); err != nil {
+		t.Fatalf("failed to remove listener (%v)", err)
+	}
+	// Batches are handed to each receiver in turn so once first has been
+	// given two more, anything sent to second before its removal is in
+	// its buffer
+	var removedAt int
+	for i := 0; i < 2; i++ {
+		removedAt = len(second)
+		firstAt := atomic.LoadInt32(&received)
+		waitFor("the remaining listener to receive", func() bool {
+			return atomic.LoadInt32(&received) >= firstAt+2
+		})
+	}
+	if got := len(second); got != removedAt {
+		t.Errorf("expected the removed listener to stop receiving, got %d more batches", got-removedAt)
+	}
+	if subs := server.Subscriptions(client.SessionInfo().ClientID); len(subs) != 1 {
+		t.Errorf("expected the channel to stay subscribed, got %v", subs)
+	}
+
+	if err := client.RemoveListener("/foo/bar", first); err != nil {
+		t.Fatalf("failed to remove listener (%v)", err)
+	}
+	waitFor("the last listener's removal to unsubscribe", func() bool {
+		return len(server.Subscriptions(client.SessionInfo().ClientID)) == 0
+	})
+	if err := client.RemoveListener("/foo/bar", first); !errors.Is(err, gobayeux.ErrNotSubscribed) {
+		t.Errorf("expected ErrNotSubscribed removing a listener twice, got %v", err)
+	}
+}
+
+func TestWithContinueOnError(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.Deny("/foo/denied")
+
+	var connects int64
+	var failSubscribe int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(body, []byte(gobayeux.MetaSubscribe)) && atomic.LoadInt32(&failSubscribe) == 1 {
+			return nil, fmt.Errorf("connection reset")
+		}
+		if bytes.Contains(body, []byte(gobayeux.MetaConnect)) {
+			atomic.AddInt64(&connects, 1)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(transport),
+		gobayeux.WithContinueOnError(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	// A channel the server rejects is reported but the client keeps going
+	denied := make(chan []gobayeux.Message, 100)
+	client.Subscribe("/foo/denied", denied)
+	select {
+	case err := <-errs:
+		var subErr gobayeux.SubscriptionFailedError
+		if !errors.As(err, &subErr) {
+			t.Fatalf("expected a SubscriptionFailedError, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the rejected subscription")
+	}
+
+	msgs := make(chan []gobayeux.Message, 100)
+	client.Subscribe("/foo/bar", msgs)
+	select {
+	case <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected messages after the rejected subscription")
+	}
+	go func() {
+		for {
+			select {
+			case <-msgs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// A transport error still stops the client
+	atomic.StoreInt32(&failSubscribe, 1)
+	client.Subscribe("/foo/baz", msgs)
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "connection reset") {
+			t.Fatalf("expected the transport error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the transport error")
+	}
+
+	stopped := atomic.LoadInt64(&connects)
+	time.Sleep(100 * time.Millisecond)
+	if got := atomic.LoadInt64(&connects); got != stopped {
+		t.Errorf("expected no connects after the transport error, got %d more", got-stopped)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	readyCtx, readyCancel := context.WithTimeout(ctx, 5*time.Second)
+	defer readyCancel()
+	if err := client.WaitReady(readyCtx); err != nil {
+		t.Fatalf("expected the client to become ready, got %v", err)
+	}
+	if state := client.State().State; state != "CONNECTED" {
+		t.Errorf("expected the client to be connected once ready, got %v", state)
+	}
+
+	// Subscribing no longer races the handshake so the channel needs no
+	// buffer
+	msgs := make(chan []gobayeux.Message)
+	client.Subscribe("/foo/bar", msgs)
+	select {
+	case <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for messages")
+	}
+}
+
+func TestWaitReadyHandshakeFailure(t *testing.T) {
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Status:     http.StatusText(http.StatusInternalServerError),
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	})
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx := context.Background()
+	errs := client.Start(ctx)
+	err = client.WaitReady(ctx)
+	var handshakeErr gobayeux.HandshakeFailedError
+	if !errors.As(err, &handshakeErr) {
+		t.Errorf("expected a HandshakeFailedError, got %v", err)
+	}
+	if startErr := <-errs; startErr.Error() != err.Error() {
+		t.Errorf("expected Start to report the same error, got %v", startErr)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	unstarted, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if err := unstarted.WaitReady(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context's error before starting, got %v", err)
+	}
+}
+
+func TestSubscribeAfterDisconnect(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to start client (%v)", err)
+	}
+	if err := client.Disconnect(ctx); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+
+	// More requests than the queues can buffer would block if the client
+	// accepted them
+	msgs := make(chan []gobayeux.Message)
+	for i := 0; i < 20; i++ {
+		if err := client.Subscribe("/foo/bar", msgs); !errors.Is(err, gobayeux.ErrClientClosed) {
+			t.Fatalf("expected ErrClientClosed subscribing after Disconnect, got %v", err)
+		}
+		if err := client.Unsubscribe("/foo/bar"); !errors.Is(err, gobayeux.ErrClientClosed) {
+			t.Fatalf("expected ErrClientClosed unsubscribing after Disconnect, got %v", err)
+		}
+	}
+	if err := client.Subscribe(gobayeux.MetaConnect, msgs); !errors.Is(err, gobayeux.ErrClientClosed) {
+		t.Errorf("expected ErrClientClosed subscribing to /meta/connect after Disconnect, got %v", err)
+	}
+}
+
+func TestDisconnectUnresponsiveServer(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	// After the handshake the server holds every request until the client
+	// gives up on it
+	var requests int64
+	connectCancelled := make(chan struct{})
+	var once sync.Once
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(body, []byte(gobayeux.MetaHandshake)) {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			return server.RoundTrip(r)
+		}
+		atomic.AddInt64(&requests, 1)
+		<-r.Context().Done()
+		if bytes.Contains(body, []byte(gobayeux.MetaConnect)) {
+			once.Do(func() { close(connectCancelled) })
+		}
+		return nil, r.Context().Err()
+	})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	errs := client.Start(context.Background())
+	if err := client.WaitReady(context.Background()); err != nil {
+		t.Fatalf("failed to start client (%v)", err)
+	}
+
+	start := time.Now()
+	err = client.DisconnectWithTimeout(100 * time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the disconnect to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected Disconnect to give up promptly, took %s", elapsed)
+	}
+
+	select {
+	case <-connectCancelled:
+	default:
+		t.Error("expected the pending /meta/connect to be cancelled")
+	}
+	select {
+	case err := <-errs:
+		t.Errorf("expected no error from the stopped client, got %v", err)
+	default:
+	}
+	stopped := atomic.LoadInt64(&requests)
+	time.Sleep(50 * time.Millisecond)
+	if got := atomic.LoadInt64(&requests); got != stopped {
+		t.Errorf("expected no requests after Disconnect, got %d more", got-stopped)
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	// Clients left running by other tests are not ours to check
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	errs := client.Start(context.Background())
+
+	// Nobody reads msgs after the first batch so delivery blocks
+	msgs := make(chan []gobayeux.Message)
+	client.Subscribe("/foo/bar", msgs)
+	// Nor is anything read from the typed subscription
+	if _, err := gobayeux.Subscribe[json.RawMessage](client, "/foo/baz"); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	select {
+	case <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for messages")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("failed to close (%v)", err)
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("expected closing again to succeed, got %v", err)
+	}
+
+	unstarted, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if err := unstarted.Close(); err != nil {
+		t.Errorf("expected closing a client which never started to succeed, got %v", err)
+	}
+}
+
+func TestWithOnSubscribe(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.Deny("/foo/denied")
+	// Without events to deliver each /meta/connect is briefly held instead
+	// of the client spinning through them
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 10})
+
+	type result struct {
+		channel gobayeux.Channel
+		err     error
+	}
+	subscribed := make(chan result, 10)
+	unsubscribed := make(chan result, 10)
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithContinueOnError(true),
+		gobayeux.WithOnSubscribe(func(ch gobayeux.Channel, err error) {
+			subscribed <- result{ch, err}
+		}),
+		gobayeux.WithOnUnsubscribe(func(ch gobayeux.Channel, err error) {
+			unsubscribed <- result{ch, err}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	msgs := make(chan []gobayeux.Message)
+	if err := client.SubscribeMany([]gobayeux.Channel{"/foo/bar", "/foo/denied"}, msgs); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	// The rejected subscription is also reported by Start
+	select {
+	case <-errs:
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the subscription error")
+	}
+
+	results := make(map[gobayeux.Channel]error)
+	for len(results) < 2 {
+		select {
+		case r := <-subscribed:
+			results[r.channel] = r.err
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for subscribe results, got %v", results)
+		}
+	}
+	if err := results["/foo/bar"]; err != nil {
+		t.Errorf("expected subscribing to /foo/bar to succeed, got %v", err)
+	}
+	if err := results["/foo/denied"]; err == nil {
+		t.Error("expected subscribing to /foo/denied to fail")
+	}
+
+	if err := client.Unsubscribe("/foo/bar"); err != nil {
+		t.Fatalf("failed to unsubscribe (%v)", err)
+	}
+	select {
+	case r := <-unsubscribed:
+		if r.channel != "/foo/bar" || r.err != nil {
+			t.Errorf("expected unsubscribing from /foo/bar to succeed, got %v for %s", r.err, r.channel)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the unsubscribe result")
+	}
+}
+
+func TestWithChannelBuffers(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	const burst = 50
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithChannelBuffers(gobayeux.ChannelBufferConfig{Subscribe: burst}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	// Nothing is taken off the queue before Start so the burst only fits
+	// in the larger buffer
+	msgs := make(chan []gobayeux.Message, burst)
+	queued := make(chan error, 1)
+	go func() {
+		for i := 0; i < burst; i++ {
+			if err := client.Subscribe(gobayeux.Channel(fmt.Sprintf("/foo/%d", i)), msgs); err != nil {
+				queued <- err
+				return
+			}
+		}
+		queued <- nil
+	}()
+	select {
+	case err := <-queued:
+		if err != nil {
+			t.Fatalf("failed to subscribe (%v)", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out queueing subscriptions")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	for len(server.Subscriptions(client.SessionInfo().ClientID)) < burst {
+		select {
+		case <-msgs:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the subscriptions")
+		}
+	}
+}
+
+func TestPublishSync(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 50})
+	server.Deny("/foo/denied")
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	msgs := make(chan []gobayeux.Message, 10)
+	if err := client.Subscribe("/foo/bar", msgs); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	clientID := client.SessionInfo().ClientID
+	for len(server.Subscriptions(clientID)) == 0 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the subscription")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if err := client.PublishSync(ctx, "/foo/bar", json.RawMessage(`{"n":1}`)); err != nil {
+		t.Fatalf("failed to publish (%v)", err)
+	}
+	select {
+	case ms := <-msgs:
+		if len(ms) != 1 || string(ms[0].Data) != `{"n":1}` {
+			t.Errorf("expected the published message to be delivered, got %v", ms)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the published message")
+	}
+
+	err = client.PublishSync(ctx, "/foo/denied", json.RawMessage(`{"n":2}`))
+	var publishErr gobayeux.PublishFailedError
+	if !errors.As(err, &publishErr) || publishErr.Channel != "/foo/denied" {
+		t.Fatalf("expected a PublishFailedError for /foo/denied, got %v", err)
+	}
+	if got := client.LastErrors()[gobayeux.OperationPublish]; got != err {
+		t.Errorf("expected the publish error to be recorded, got %v", got)
+	}
+
+	var invalid gobayeux.InvalidChannelError
+	if err := client.PublishSync(ctx, "/meta/connect", nil); !errors.As(err, &invalid) {
+		t.Errorf("expected an InvalidChannelError publishing to a meta channel, got %v", err)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 50})
+	server.Deny("/foo/denied")
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	msgs := make(chan []gobayeux.Message, 10)
+	if err := client.Subscribe("/foo/bar", msgs); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	clientID := client.SessionInfo().ClientID
+	for len(server.Subscriptions(clientID)) == 0 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the subscription")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	err = client.Publish(ctx, []gobayeux.Message{
+		{Channel: "/foo/bar", Data: json.RawMessage(`{"n":1}`)},
+		{Channel: "/foo/denied", Data: json.RawMessage(`{"n":2}`)},
+		{Channel: "/foo/bar", Data: json.RawMessage(`{"n":3}`)},
+	})
+	var publishErr gobayeux.PublishFailedError
+	if !errors.As(err, &publishErr) || publishErr.Channel != "/foo/denied" {
+		t.Fatalf("expected a PublishFailedError for /foo/denied, got %v", err)
+	}
+
+	select {
+	case ms := <-msgs:
+		if len(ms) != 1 || string(ms[0].Data) != `{"n":1}` {
+			t.Errorf("expected only the message before the failure to be published, got %v", ms)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the published message")
+	}
+}
+
+func TestWithRehandshakeNotices(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	// Advise a re-handshake in the reply to the first /meta/connect
+	var advised int32
+	var firstClientID atomic.Value
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		resp, err := server.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			return nil, err
+		}
+		for i := range ms {
+			if ms[i].Channel == gobayeux.MetaConnect && atomic.CompareAndSwapInt32(&advised, 0, 1) {
+				firstClientID.Store(ms[i].ClientID)
+				ms[i].Advice = &gobayeux.Advice{Reconnect: gobayeux.ReconnectHandshake}
+			}
+		}
+		body, err := json.Marshal(ms)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	})
+
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(transport),
+		gobayeux.WithRehandshakeNotices(),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+
+	select {
+	case err := <-errs:
+		var notice gobayeux.RehandshakeNotice
+		if !errors.As(err, &notice) {
+			t.Fatalf("expected a RehandshakeNotice, got %v", err)
+		}
+		if want, _ := firstClientID.Load().(string); notice.ClientID != want {
+			t.Errorf("expected the notice to name session %q, got %q", want, notice.ClientID)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the re-handshake notice")
+	}
+}
+
+func TestWithSubscriptions(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	bars := make(chan []gobayeux.Message, 10)
+	bazs := make(chan []gobayeux.Message, 10)
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithSubscriptions([]gobayeux.SubscriptionSpec{
+			{Channel: "/foo/bar", Receiving: bars},
+			{Channel: "/foo/baz", Receiving: bazs},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to start (%v)", err)
+	}
+
+	subscriptions := server.Subscriptions(client.SessionInfo().ClientID)
+	if !reflect.DeepEqual(subscriptions, []gobayeux.Channel{"/foo/bar", "/foo/baz"}) {
+		t.Errorf("expected both channels to be subscribed once ready, got %v", subscriptions)
+	}
+	for _, receiving := range []chan []gobayeux.Message{bars, bazs} {
+		select {
+		case <-receiving:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+}
+
+func TestPing(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	// Hold every /meta/connect so that Ping runs alongside the long poll
+	server.SetGenerateEvents(false)
+
+	var down int32
+	var pings int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		if atomic.LoadInt32(&down) == 1 {
+			return nil, errors.New("connection refused")
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(body, []byte(gobayeux.DefaultPingChannel)) {
+			atomic.AddInt32(&pings, 1)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("expected Ping to succeed, got %v", err)
+	}
+	if got := atomic.LoadInt32(&pings); got != 1 {
+		t.Errorf("expected one request to the ping channel, got %d", got)
+	}
+
+	atomic.StoreInt32(&down, 1)
+	if err := client.Ping(ctx); err == nil {
+		t.Error("expected Ping to fail while the server is down")
+	}
+}
+
+func TestWithPingChannel(t *testing.T) {
+	_, err := gobayeux.NewClient("https://example.com", gobayeux.WithPingChannel("/foo/bar"))
+	var invalid gobayeux.InvalidChannelError
+	if !errors.As(err, &invalid) {
+		t.Errorf("expected an InvalidChannelError for a broadcast ping channel, got %v", err)
+	}
+}
+
+func TestMultipleClientsAdviceRehandshakes(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	// Advise multiple-clients in the reply to the first /meta/connect
+	var advised, handshakes int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		resp, err := server.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			return nil, err
+		}
+		for i := range ms {
+			switch {
+			case ms[i].Channel == gobayeux.MetaHandshake:
+				atomic.AddInt32(&handshakes, 1)
+			case ms[i].Channel == gobayeux.MetaConnect && atomic.CompareAndSwapInt32(&advised, 0, 1):
+				ms[i].Advice = &gobayeux.Advice{Reconnect: gobayeux.ReconnectRetry, MultipleClients: true}
+			}
+		}
+		body, err := json.Marshal(ms)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	firstClientID := client.SessionInfo().ClientID
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, gobayeux.ErrMultipleClients) {
+			t.Fatalf("expected ErrMultipleClients, got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the multiple-clients error")
+	}
+
+	for atomic.LoadInt32(&handshakes) < 2 || client.SessionInfo().ClientID == firstClientID {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the client to handshake again")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestWithMaxDeliveryBatch(t *testing.T) {
+	// The first /meta/connect after subscribing delivers 1000 messages on
+	// /foo/bar and every later one asks the client to wait
+	var delivered int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		var requests []gobayeux.Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []gobayeux.Message
+		for _, m := range requests {
+			reply := gobayeux.Message{
+				Channel:                  m.Channel,
+				ID:                       m.ID,
+				ClientID:                 "abc",
+				Successful:               true,
+				Subscription:             m.Subscription,
+				SupportedConnectionTypes: []string{gobayeux.ConnectionTypeLongPolling},
+				Version:                  "1.0",
+			}
+			if m.Channel == gobayeux.MetaConnect {
+				reply.Advice = &gobayeux.Advice{Reconnect: gobayeux.ReconnectRetry, Interval: 60000}
+			}
+			replies = append(replies, reply)
+			if m.Channel == gobayeux.MetaSubscribe {
+				atomic.StoreInt32(&delivered, 1)
+			} else if m.Channel == gobayeux.MetaConnect && atomic.CompareAndSwapInt32(&delivered, 1, 2) {
+				for i := 0; i < 1000; i++ {
+					replies = append(replies, gobayeux.Message{
+						Channel: "/foo/bar",
+						ID:      strconv.Itoa(i),
+						Data:    json.RawMessage(`{}`),
+					})
+				}
+			}
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(transport),
+		gobayeux.WithMaxDeliveryBatch(300),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	msgs := make(chan []gobayeux.Message, 10)
+	if err := client.Subscribe("/foo/bar", msgs); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+
+	var sizes []int
+	next := 0
+	for next < 1000 {
+		select {
+		case batch := <-msgs:
+			sizes = append(sizes, len(batch))
+			for _, m := range batch {
+				if m.ID != strconv.Itoa(next) {
+					t.Fatalf("expected message %d next, got %s", next, m.ID)
+				}
+				next++
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("timed out after %d messages", next)
+		}
+	}
+	if !reflect.DeepEqual(sizes, []int{300, 300, 300, 100}) {
+		t.Errorf("expected batches of at most 300, got %v", sizes)
+	}
+}
+
+func TestUnsubscribeAll(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 50})
+
+	var unsubscribes int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Contains(body, []byte(gobayeux.MetaUnsubscribe)) {
+			atomic.AddInt32(&unsubscribes, 1)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+
+	msgs := make(chan []gobayeux.Message, 10)
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(transport),
+		gobayeux.WithSubscriptions([]gobayeux.SubscriptionSpec{
+			{Channel: "/foo/a", Receiving: msgs},
+			{Channel: "/foo/b", Receiving: msgs},
+			{Channel: "/foo/c", Receiving: msgs},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to start (%v)", err)
+	}
+	clientID := client.SessionInfo().ClientID
+	if got := server.Subscriptions(clientID); len(got) != 3 {
+		t.Fatalf("expected 3 subscriptions, got %v", got)
+	}
+
+	if err := client.UnsubscribeAll(); err != nil {
+		t.Fatalf("failed to unsubscribe (%v)", err)
+	}
+	for len(server.Subscriptions(clientID)) > 0 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting to unsubscribe, still subscribed to %v", server.Subscriptions(clientID))
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if got := atomic.LoadInt32(&unsubscribes); got != 1 {
+		t.Errorf("expected a single /meta/unsubscribe request, got %d", got)
+	}
+}
+
+func TestEventsSentWithHandshakeAreDelivered(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 50})
+
+	// The handshake reply is sent with an event on each channel
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		resp, err := server.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			return nil, err
+		}
+		if len(ms) == 1 && ms[0].Channel == gobayeux.MetaHandshake {
+			ms = append(ms,
+				gobayeux.Message{Channel: "/foo/bar", ID: "1", Data: json.RawMessage(`"welcome"`)},
+				gobayeux.Message{Channel: "/foo/baz", ID: "2", Data: json.RawMessage(`"ignored"`)},
+			)
+		}
+		body, err := json.Marshal(ms)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	})
+
+	msgs := make(chan []gobayeux.Message, 10)
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(transport),
+		gobayeux.WithSubscriptions([]gobayeux.SubscriptionSpec{{Channel: "/foo/bar", Receiving: msgs}}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+
+	select {
+	case ms := <-msgs:
+		if len(ms) != 1 || string(ms[0].Data) != `"welcome"` {
+			t.Errorf("expected the event sent with the handshake, got %v", ms)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the event sent with the handshake")
+	}
+}
+
+func TestStaleConnectReplyIsIgnored(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 50})
+
+	// The first /meta/connect is answered with the reply to an earlier
+	// request along with an event for the session
+	var connects int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		resp, err := server.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			return nil, err
+		}
+		for i := range ms {
+			if ms[i].Channel == gobayeux.MetaConnect && atomic.AddInt32(&connects, 1) == 1 {
+				ms[i].ID = "0"
+				ms = append(ms, gobayeux.Message{Channel: "/foo/bar", ID: "1", Data: json.RawMessage(`"late"`)})
+				break
+			}
+		}
+		body, err := json.Marshal(ms)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	})
+
+	msgs := make(chan []gobayeux.Message, 10)
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(transport),
+		gobayeux.WithSubscriptions([]gobayeux.SubscriptionSpec{{Channel: "/foo/bar", Receiving: msgs}}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+
+	select {
+	case ms := <-msgs:
+		if len(ms) != 1 || string(ms[0].Data) != `"late"` {
+			t.Errorf("expected the event sent with the stale reply, got %v", ms)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the event sent with the stale reply")
+	}
+
+	for atomic.LoadInt32(&connects) < 2 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the client to connect again")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
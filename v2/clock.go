@@ -0,0 +1,29 @@
+package gobayeux
+
+import "time"
+
+// Clock is the source of time for the Client's timers, e.g., waiting for the
+// interval advised by the server before connecting again
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the default Clock backed by the time package
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// withClock returns an Option which replaces the Client's Clock so tests can
+// control the passing of time
+func withClock(clock Clock) Option {
+	return func(options *Options) {
+		options.clock = clock
+	}
+}
@@ -0,0 +1,138 @@
+package gobayeux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves forward when Advance is called
+type fakeClock struct {
+	lock    sync.Mutex
+	now     time.Time
+	waiters []fakeTimer
+	added   chan struct{}
+}
+
+type fakeTimer struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(0, 0), added: make(chan struct{}, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeTimer{c.now.Add(d), ch})
+	c.added <- struct{}{}
+	return ch
+}
+
+// Advance moves the clock forward, firing every timer which is then due, and
+// returns how many timers are still pending
+func (c *fakeClock) Advance(d time.Duration) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			pending = append(pending, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = pending
+	return len(pending)
+}
+
+// transportFn lets a function serve as an http.RoundTripper
+type transportFn func(*http.Request) (*http.Response, error)
+
+func (f transportFn) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestPollHonorsAdviceInterval(t *testing.T) {
+	var connects int32
+	connected := make(chan struct{}, 10)
+	transport := transportFn(func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			reply := Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true}
+			if m.Channel == MetaConnect {
+				reply.Advice = &Advice{Reconnect: "retry", Interval: 60000}
+				atomic.AddInt32(&connects, 1)
+				defer func() { connected <- struct{}{} }()
+			}
+			replies = append(replies, reply)
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	clock := newFakeClock()
+	client, err := NewClient("https://example.com", WithHTTPTransport(transport), withClock(clock))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	waitFor := func(ch <-chan struct{}, what string) {
+		t.Helper()
+		select {
+		case <-ch:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+
+	waitFor(connected, "the first connect")
+	waitFor(clock.added, "the client to wait for the advised interval")
+
+	if pending := clock.Advance(59 * time.Second); pending != 1 {
+		t.Fatalf("expected the client to still be waiting before the interval, %d timers pending", pending)
+	}
+	if got := atomic.LoadInt32(&connects); got != 1 {
+		t.Errorf("expected one connect before the interval elapsed, got %d", got)
+	}
+
+	if pending := clock.Advance(time.Second); pending != 0 {
+		t.Errorf("expected the interval to have elapsed, %d timers pending", pending)
+	}
+	waitFor(connected, "the connect after the interval")
+	if got := atomic.LoadInt32(&connects); got != 2 {
+		t.Errorf("expected a second connect once the interval elapsed, got %d", got)
+	}
+}
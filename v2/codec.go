@@ -0,0 +1,117 @@
+package gobayeux
+
+import (
+	"encoding/json"
+	"mime"
+	"strings"
+	"sync"
+)
+
+// Codec defines how messages are marshaled before being sent to the Bayeux
+// server and unmarshaled when they are received from it. The default Codec
+// uses encoding/json.
+type Codec interface {
+	// Marshal returns the encoding of v
+	Marshal(v any) ([]byte, error)
+
+	// Unmarshal parses the encoded data and stores the result in the value
+	// pointed to by v
+	Unmarshal(data []byte, v any) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// jsonMediaType is the media type of messages encoded by the default Codec
+// and any other Codec which is not a MediaTypeCodec
+const jsonMediaType = "application/json"
+
+// MediaTypeCodec is a Codec for a wire format other than JSON, such as
+// MessagePack, which can be negotiated with servers that support it.
+//
+// The Data field of a Message is always JSON so MediaTypeCodecs must encode
+// it as whatever the equivalent structure in their format is.
+type MediaTypeCodec interface {
+	Codec
+
+	// MediaType is the value of the Content-Type and Accept headers for the
+	// wire format, e.g., "application/msgpack"
+	MediaType() string
+}
+
+// WithNegotiatedCodecs returns an Option which offers the codecs to the
+// server, in order of preference, in the Accept header of every request.
+// Requests are sent using the Codec configured by WithCodec until the server
+// responds using one of the codecs, after which they are sent using that one.
+func WithNegotiatedCodecs(codecs ...MediaTypeCodec) Option {
+	return func(options *Options) {
+		options.NegotiatedCodecs = codecs
+	}
+}
+
+// codecNegotiation tracks the wire format agreed with the server
+type codecNegotiation struct {
+	fallback Codec
+	offered  []MediaTypeCodec
+	accept   string
+
+	lock   sync.RWMutex
+	agreed MediaTypeCodec
+}
+
+func newCodecNegotiation(fallback Codec, offered []MediaTypeCodec) *codecNegotiation {
+	mediaTypes := make([]string, 0, len(offered)+1)
+	for _, codec := range offered {
+		mediaTypes = append(mediaTypes, codec.MediaType())
+	}
+	mediaTypes = append(mediaTypes, jsonMediaType)
+
+	return &codecNegotiation{
+		fallback: fallback,
+		offered:  offered,
+		accept:   strings.Join(mediaTypes, ", "),
+	}
+}
+
+// request returns the Codec to encode a request with and its media type
+func (n *codecNegotiation) request() (Codec, string) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	if n.agreed != nil {
+		return n.agreed, n.agreed.MediaType()
+	}
+	if codec, ok := n.fallback.(MediaTypeCodec); ok {
+		return codec, codec.MediaType()
+	}
+	return n.fallback, jsonMediaType
+}
+
+// response returns the Codec to decode a response with the given
+// Content-Type with and whether it is a JSON one. A response in one of the
+// offered formats means the server has agreed to use it.
+func (n *codecNegotiation) response(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err == nil {
+		for _, codec := range n.offered {
+			if codec.MediaType() == mediaType {
+				n.lock.Lock()
+				n.agreed = codec
+				n.lock.Unlock()
+				return codec, false
+			}
+		}
+	}
+
+	if codec, ok := n.fallback.(MediaTypeCodec); ok {
+		return codec, false
+	}
+	return n.fallback, true
+}
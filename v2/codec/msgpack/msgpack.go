@@ -0,0 +1,363 @@
+// Package msgpack provides a MessagePack gobayeux.Codec for servers which
+// support a more compact wire format than JSON.
+//
+// Messages are converted through their JSON representation so the Codec
+// honours the same struct tags and json.Marshaler implementations as the
+// default Codec, including the Data of each message.
+//
+// Example Usage:
+//
+//	client, err := gobayeux.NewClient(serverAddress, gobayeux.WithNegotiatedCodecs(msgpack.Codec{}))
+//
+// See also: https://github.com/msgpack/msgpack/blob/master/spec.md
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// MediaType is the media type used for MessagePack requests and responses
+const MediaType = "application/msgpack"
+
+// ErrUnexpectedEnd is returned when the data ends part way through a value
+var ErrUnexpectedEnd = errors.New("msgpack: unexpected end of data")
+
+// ErrTooDeep is returned when arrays and maps are nested more than maxDepth
+// levels deep
+var ErrTooDeep = errors.New("msgpack: exceeded max depth")
+
+// maxDepth limits nesting like encoding/json does so that a hostile response
+// cannot overflow the stack
+const maxDepth = 10000
+
+// Codec implements gobayeux.MediaTypeCodec for MessagePack
+type Codec struct{}
+
+// MediaType implements gobayeux.MediaTypeCodec
+func (Codec) MediaType() string {
+	return MediaType
+}
+
+// Marshal implements gobayeux.Codec
+func (Codec) Marshal(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements gobayeux.Codec
+func (Codec) Unmarshal(data []byte, v any) error {
+	d := &decoder{data: data}
+	value, err := d.decode()
+	if err != nil {
+		return err
+	}
+	if d.pos != len(d.data) {
+		return fmt.Errorf("msgpack: %d bytes of trailing data", len(d.data)-d.pos)
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// encode writes the MessagePack encoding of a value decoded from JSON
+func encode(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			encodeInt(buf, i)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(0xcb)
+		_ = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		encodeHeader(buf, len(v), 0xa0, 32, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []any:
+		encodeHeader(buf, len(v), 0x90, 16, 0, 0xdc, 0xdd)
+		for _, elem := range v {
+			if err := encode(buf, elem); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		encodeHeader(buf, len(v), 0x80, 16, 0, 0xde, 0xdf)
+		// Sorting the keys keeps the encoding deterministic
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := encode(buf, k); err != nil {
+				return err
+			}
+			if err := encode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %T", value)
+	}
+	return nil
+}
+
+func encodeInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(int8(i)))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		_ = binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		_ = binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		_ = binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+// encodeHeader writes the type and length prefix of a string, array, or map.
+// Short values use fixed with the length in the low bits, up to fixedLimit.
+// Formats without an 8-bit length variant pass 0 for len8.
+func encodeHeader(buf *bytes.Buffer, n int, fixed byte, fixedLimit int, len8, len16, len32 byte) {
+	switch {
+	case n < fixedLimit:
+		buf.WriteByte(fixed | byte(n))
+	case len8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(len8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(len16)
+		_ = binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(len32)
+		_ = binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// decoder reads MessagePack values into the types encoding/json produces
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// enter records that the decoder descends into an array or map
+func (d *decoder) enter() error {
+	if d.depth++; d.depth > maxDepth {
+		return ErrTooDeep
+	}
+	return nil
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || len(d.data)-d.pos < n {
+		return nil, ErrUnexpectedEnd
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint(size int) (uint64, error) {
+	b, err := d.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *decoder) decode() (any, error) {
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := b[0]; {
+	case t <= 0x7f:
+		return json.Number(strconv.Itoa(int(t))), nil
+	case t >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(t)))), nil
+	case t&0xe0 == 0xa0:
+		return d.string(int(t & 0x1f))
+	case t&0xf0 == 0x90:
+		return d.array(int(t & 0x0f))
+	case t&0xf0 == 0x80:
+		return d.object(int(t & 0x0f))
+	}
+
+	switch t := b[0]; t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (t - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(strconv.FormatUint(n, 10)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (t - 0xd0)
+		n, err := d.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign extend from the encoded width
+		shift := 64 - 8*size
+		return json.Number(strconv.FormatInt(int64(n<<shift)>>shift, 10)), nil
+	case 0xca:
+		n, err := d.uint(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(n))), nil
+	case 0xcb:
+		n, err := d.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(n), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (t - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.string(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		// JSON has no binary type so it becomes base64 like a []byte
+		n, err := d.uint(1 << (t - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		raw, err := d.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (t - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (t - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%02x", b[0])
+}
+
+func (d *decoder) string(n int) (string, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (d *decoder) array(n int) ([]any, error) {
+	if n > len(d.data)-d.pos {
+		return nil, ErrUnexpectedEnd
+	}
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+	values := make([]any, n)
+	for i := range values {
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (d *decoder) object(n int) (map[string]any, error) {
+	if n > len(d.data)-d.pos {
+		return nil, ErrUnexpectedEnd
+	}
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+	object := make(map[string]any, n)
+	for i := 0; i < n; i++ {
+		key, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		value, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case string:
+			object[k] = value
+		case json.Number:
+			object[string(k)] = value
+		default:
+			return nil, fmt.Errorf("msgpack: unsupported map key %T", key)
+		}
+	}
+	return object, nil
+}
@@ -0,0 +1,220 @@
+package msgpack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestMarshalEncoding(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value any
+		want  []byte
+	}{
+		{"nil", nil, []byte{0xc0}},
+		{"true", true, []byte{0xc3}},
+		{"positive fixint", 7, []byte{0x07}},
+		{"negative fixint", -3, []byte{0xfd}},
+		{"int 16", 1000, []byte{0xd1, 0x03, 0xe8}},
+		{"float", 1.5, []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{"fixstr", "abc", []byte{0xa3, 'a', 'b', 'c'}},
+		{"fixarray", []int{1, 2}, []byte{0x92, 0x01, 0x02}},
+		{"fixmap", map[string]int{"a": 1}, []byte{0x81, 0xa1, 'a', 0x01}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Codec{}.Marshal(tc.value)
+			if err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("expected % x, got % x", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRoundTripValues(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value any
+	}{
+		{"int 8", int64(-100)},
+		{"int 32", int64(-100000)},
+		{"int 64", int64(1) << 40},
+		{"uint 8", int64(200)},
+		{"str 8", strings.Repeat("x", 100)},
+		{"str 16", strings.Repeat("x", 1000)},
+		{"array 16", make([]int64, 20)},
+		{"nested", map[string]any{"a": []any{"b", true, nil}, "c": map[string]any{"d": 1.25}}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := Codec{}.Marshal(tc.value)
+			if err != nil {
+				t.Fatalf("failed to marshal (%v)", err)
+			}
+
+			got := reflect.New(reflect.TypeOf(tc.value))
+			if err := (Codec{}).Unmarshal(b, got.Interface()); err != nil {
+				t.Fatalf("failed to unmarshal (%v)", err)
+			}
+			if !reflect.DeepEqual(got.Elem().Interface(), tc.value) {
+				t.Errorf("expected %v, got %v", tc.value, got.Elem().Interface())
+			}
+		})
+	}
+}
+
+func TestRoundTripMessages(t *testing.T) {
+	want := []gobayeux.Message{
+		{
+			Channel:  gobayeux.MetaConnect,
+			ClientID: "abc",
+			Advice:   &gobayeux.Advice{Reconnect: "retry", Timeout: 30000, Interval: 0},
+			Ext:      map[string]interface{}{"ack": float64(3)},
+		},
+		{
+			Channel: "/foo/bar",
+			ID:      "1",
+			Data:    json.RawMessage(`{"event":{"replayId":42},"payload":[1,"two",null]}`),
+		},
+	}
+
+	b, err := Codec{}.Marshal(want)
+	if err != nil {
+		t.Fatalf("failed to marshal (%v)", err)
+	}
+
+	var got []gobayeux.Message
+	if err := (Codec{}).Unmarshal(b, &got); err != nil {
+		t.Fatalf("failed to unmarshal (%v)", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestUnmarshalTruncated(t *testing.T) {
+	b, err := Codec{}.Marshal(map[string]string{"channel": "/meta/connect"})
+	if err != nil {
+		t.Fatalf("failed to marshal (%v)", err)
+	}
+
+	var v map[string]string
+	if err := (Codec{}).Unmarshal(b[:len(b)-1], &v); !errors.Is(err, ErrUnexpectedEnd) {
+		t.Errorf("expected ErrUnexpectedEnd, got %v", err)
+	}
+}
+
+func TestUnmarshalDeeplyNested(t *testing.T) {
+	// Each 0x91 opens an array holding one value, the last of which is nil
+	nested := func(depth int) []byte {
+		return append(bytes.Repeat([]byte{0x91}, depth), 0xc0)
+	}
+
+	var v any
+	if err := (Codec{}).Unmarshal(nested(maxDepth), &v); err != nil {
+		t.Errorf("expected %d levels to decode, got %v", maxDepth, err)
+	}
+	for _, depth := range []int{maxDepth + 1, 1 << 20} {
+		if err := (Codec{}).Unmarshal(nested(depth), &v); !errors.Is(err, ErrTooDeep) {
+			t.Errorf("expected ErrTooDeep for %d levels, got %v", depth, err)
+		}
+	}
+}
+
+// negotiatingServer answers in MessagePack whenever the client accepts it and
+// records the Content-Type of each request
+type negotiatingServer struct {
+	mu           sync.Mutex
+	contentTypes []string
+}
+
+func (s *negotiatingServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.contentTypes = append(s.contentTypes, r.Header.Get("Content-Type"))
+	s.mu.Unlock()
+
+	var requests []gobayeux.Message
+	var decode func([]byte, any) error = json.Unmarshal
+	if r.Header.Get("Content-Type") == MediaType {
+		decode = Codec{}.Unmarshal
+	}
+	if err := decode(body, &requests); err != nil {
+		return nil, err
+	}
+
+	replies := make([]gobayeux.Message, 0, len(requests))
+	for _, m := range requests {
+		replies = append(replies, gobayeux.Message{
+			Channel:                  m.Channel,
+			ClientID:                 "abc",
+			Successful:               true,
+			SupportedConnectionTypes: []string{gobayeux.ConnectionTypeLongPolling},
+		})
+	}
+
+	contentType := "application/json"
+	encode := json.Marshal
+	if strings.Contains(r.Header.Get("Accept"), MediaType) {
+		contentType = MediaType
+		encode = Codec{}.Marshal
+	}
+	reply, err := encode(replies)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Header:     http.Header{"Content-Type": {contentType}},
+		Body:       io.NopCloser(bytes.NewReader(reply)),
+	}, nil
+}
+
+func TestNegotiation(t *testing.T) {
+	server := &negotiatingServer{}
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil,
+		gobayeux.WithNegotiatedCodecs(Codec{}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	if _, err := client.Handshake(context.Background()); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	ms, err := client.Connect(context.Background())
+	if err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+	if len(ms) != 1 || ms[0].Channel != gobayeux.MetaConnect || !ms[0].Successful {
+		t.Errorf("expected a successful /meta/connect reply, got %+v", ms)
+	}
+
+	want := []string{"application/json", MediaType}
+	if !reflect.DeepEqual(server.contentTypes, want) {
+		t.Errorf("expected requests encoded as %v, got %v", want, server.contentTypes)
+	}
+}
@@ -0,0 +1,59 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+type roundTripFn func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFn) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+type numberCodec struct{}
+
+func (numberCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (numberCodec) Unmarshal(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func TestWithCodec(t *testing.T) {
+	handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body := `[{"channel":"/meta/handshake","clientId":"abc","successful":true,"ext":{"replayId":12345678901234567890}}]`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewBufferString(body)),
+		}, nil
+	})
+
+	client, err := gobayeux.NewBayeuxClient(nil, handler, "https://example.com", nil, gobayeux.WithCodec(numberCodec{}))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ms, err := client.Handshake(context.Background())
+	if err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	got, ok := ms[0].Ext["replayId"].(json.Number)
+	if !ok {
+		t.Fatalf("expected replayId to be decoded as a json.Number, got %T", ms[0].Ext["replayId"])
+	}
+	if want := json.Number("12345678901234567890"); got != want {
+		t.Errorf("expected replayId %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,27 @@
+package gobayeux
+
+import "context"
+
+// RequestInfo describes the operation a request belongs to. It is carried by
+// the context passed to RequestObserver and ContextMessageExtender
+// implementations.
+type RequestInfo struct {
+	// Operation is the kind of operation, one of the Operation constants
+	Operation string
+	// ClientID is the client ID assigned by the server when the request
+	// started. It is empty before the first successful handshake.
+	ClientID string
+}
+
+type requestInfoKey struct{}
+
+// RequestInfoFromContext returns the RequestInfo stored in the context by
+// BayeuxClient and whether there was one
+func RequestInfoFromContext(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info, ok
+}
+
+func withRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
@@ -0,0 +1,127 @@
+package gobayeux_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+type contextExtension struct {
+	mu       sync.Mutex
+	outgoing []gobayeux.RequestInfo
+	incoming []gobayeux.RequestInfo
+	plain    int
+}
+
+func (e *contextExtension) Outgoing(*gobayeux.Message)                { e.plain++ }
+func (e *contextExtension) Incoming(*gobayeux.Message)                { e.plain++ }
+func (e *contextExtension) Registered(string, *gobayeux.BayeuxClient) {}
+func (e *contextExtension) Unregistered()                             {}
+
+func (e *contextExtension) OutgoingContext(ctx context.Context, m *gobayeux.Message) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info, _ := gobayeux.RequestInfoFromContext(ctx)
+	e.outgoing = append(e.outgoing, info)
+	return nil
+}
+
+func (e *contextExtension) IncomingContext(ctx context.Context, m *gobayeux.Message) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	info, _ := gobayeux.RequestInfoFromContext(ctx)
+	e.incoming = append(e.incoming, info)
+	return nil
+}
+
+func TestContextMessageExtender(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ext := &contextExtension{}
+	if err := client.UseExtension(ext); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ctx := context.Background()
+	handshake, err := client.Handshake(ctx)
+	if err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+
+	clientID := handshake[0].ClientID
+	want := []gobayeux.RequestInfo{
+		{Operation: gobayeux.OperationHandshake},
+		{Operation: gobayeux.OperationSubscribe, ClientID: clientID},
+	}
+
+	ext.mu.Lock()
+	defer ext.mu.Unlock()
+	if ext.plain != 0 {
+		t.Errorf("expected Outgoing and Incoming not to be called, got %d calls", ext.plain)
+	}
+	for name, got := range map[string][]gobayeux.RequestInfo{"outgoing": ext.outgoing, "incoming": ext.incoming} {
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d messages, got %d", name, len(want), len(got))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s message %d: want %+v, got %+v", name, i, want[i], got[i])
+			}
+		}
+	}
+}
+
+func TestRequestInfoSeenByObserver(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	var infos []gobayeux.RequestInfo
+	observer := observerFn(func(ctx context.Context, kind string) (context.Context, func(error)) {
+		info, ok := gobayeux.RequestInfoFromContext(ctx)
+		if !ok {
+			t.Errorf("expected request info for %s", kind)
+		}
+		infos = append(infos, info)
+		return ctx, func(error) {}
+	})
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil, gobayeux.WithRequestObserver(observer))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+
+	if len(infos) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(infos))
+	}
+	if infos[1].Operation != gobayeux.OperationConnect || infos[1].ClientID == "" {
+		t.Errorf("expected connect with a client ID, got %+v", infos[1])
+	}
+}
+
+type observerFn func(ctx context.Context, kind string) (context.Context, func(error))
+
+func (f observerFn) StartRequest(ctx context.Context, kind string) (context.Context, func(error)) {
+	return f(ctx, kind)
+}
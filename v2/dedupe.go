@@ -0,0 +1,55 @@
+package gobayeux
+
+import "sync"
+
+// dedupeFilter remembers the IDs of the most recent messages delivered on
+// each channel so that a message delivered again can be dropped
+type dedupeFilter struct {
+	lock   sync.Mutex
+	window int
+	recent map[Channel]*recentIDs
+}
+
+// recentIDs is a ring buffer of message IDs along with a set of its contents
+type recentIDs struct {
+	ids  map[string]bool
+	ring []string
+	next int
+}
+
+func newDedupeFilter(window int) *dedupeFilter {
+	return &dedupeFilter{window: window, recent: make(map[Channel]*recentIDs)}
+}
+
+// filter returns the messages in batch whose IDs are not among the recent
+// ones, remembering them in place of the oldest. Messages without an ID are
+// always kept.
+func (d *dedupeFilter) filter(batch []Message) []Message {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	filtered := batch[:0]
+	for _, m := range batch {
+		if m.ID == "" {
+			filtered = append(filtered, m)
+			continue
+		}
+		recent, ok := d.recent[m.Channel]
+		if !ok {
+			recent = &recentIDs{ids: make(map[string]bool)}
+			d.recent[m.Channel] = recent
+		}
+		if recent.ids[m.ID] {
+			continue
+		}
+		if len(recent.ring) < d.window {
+			recent.ring = append(recent.ring, m.ID)
+		} else {
+			delete(recent.ids, recent.ring[recent.next])
+			recent.ring[recent.next] = m.ID
+			recent.next = (recent.next + 1) % d.window
+		}
+		recent.ids[m.ID] = true
+		filtered = append(filtered, m)
+	}
+	return filtered
+}
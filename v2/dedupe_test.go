@@ -0,0 +1,120 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestWithDedupeWindow(t *testing.T) {
+	// The first connect delivers events 1 to 5 and the second fails. Like a
+	// server with the ack extension, it resends from event 3 after the
+	// client re-handshakes as it never saw those acknowledged.
+	var subscribed, connects int32
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		var requests []gobayeux.Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+
+		var replies []gobayeux.Message
+		for _, m := range requests {
+			reply := gobayeux.Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true}
+			switch m.Channel {
+			case gobayeux.MetaSubscribe:
+				reply.Subscription = m.Subscription
+				atomic.StoreInt32(&subscribed, 1)
+			case gobayeux.MetaConnect:
+				if atomic.LoadInt32(&subscribed) == 0 {
+					time.Sleep(5 * time.Millisecond)
+					break
+				}
+				var first, last int
+				switch atomic.AddInt32(&connects, 1) {
+				case 1:
+					first, last = 1, 5
+				case 2:
+					return &http.Response{
+						StatusCode: http.StatusServiceUnavailable,
+						Status:     http.StatusText(http.StatusServiceUnavailable),
+						Body:       io.NopCloser(bytes.NewReader(nil)),
+					}, nil
+				case 3:
+					first, last = 3, 8
+				default:
+					time.Sleep(5 * time.Millisecond)
+				}
+				for id := first; id > 0 && id <= last; id++ {
+					replies = append(replies, gobayeux.Message{
+						Channel: "/foo/bar",
+						ID:      strconv.Itoa(id),
+						Data:    json.RawMessage(`{}`),
+					})
+				}
+			}
+			replies = append(replies, reply)
+		}
+
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(transport),
+		gobayeux.WithDedupeWindow(10),
+		gobayeux.WithStatusHandler(map[int]gobayeux.StatusAction{
+			http.StatusServiceUnavailable: {Recovery: gobayeux.RehandshakeOnStatus},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+	msgs := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/bar", msgs)
+
+	var got []string
+	for len(got) < 8 {
+		select {
+		case ms := <-msgs:
+			for _, m := range ms {
+				got = append(got, m.ID)
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out with events %v", got)
+		}
+	}
+
+	want := []string{"1", "2", "3", "4", "5", "6", "7", "8"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected each event once %v, got %v", want, got)
+	}
+	select {
+	case ms := <-msgs:
+		t.Errorf("expected no more events, got %v", ms)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
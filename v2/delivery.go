@@ -0,0 +1,102 @@
+package gobayeux
+
+import "sync/atomic"
+
+// DeliveryOrderPolicy determines the order in which the batches for each
+// channel present in a single /meta/connect response are delivered. It is
+// given the channels in the order they first appear in the response and
+// returns them in the order their batches should be delivered.
+type DeliveryOrderPolicy func(channels []Channel) []Channel
+
+// FirstSeenDeliveryOrder delivers batches in the order their channels first
+// appear in the response. This is the default.
+func FirstSeenDeliveryOrder() DeliveryOrderPolicy {
+	return func(channels []Channel) []Channel {
+		return channels
+	}
+}
+
+// RoundRobinDeliveryOrder rotates which channel is delivered first on each
+// response so that no single channel is always delivered ahead of the others.
+func RoundRobinDeliveryOrder() DeliveryOrderPolicy {
+	var turn uint64
+	return func(channels []Channel) []Channel {
+		if len(channels) == 0 {
+			return channels
+		}
+		start := int((atomic.AddUint64(&turn, 1) - 1) % uint64(len(channels)))
+		ordered := make([]Channel, 0, len(channels))
+		ordered = append(ordered, channels[start:]...)
+		return append(ordered, channels[:start]...)
+	}
+}
+
+// PriorityDeliveryOrder delivers the batches for the given channels first, in
+// the order provided, followed by any other channels in the order they first
+// appear in the response.
+func PriorityDeliveryOrder(priority ...Channel) DeliveryOrderPolicy {
+	return func(channels []Channel) []Channel {
+		present := make(map[Channel]bool, len(channels))
+		for _, channel := range channels {
+			present[channel] = true
+		}
+
+		ordered := make([]Channel, 0, len(channels))
+		for _, channel := range priority {
+			if present[channel] {
+				ordered = append(ordered, channel)
+				delete(present, channel)
+			}
+		}
+		for _, channel := range channels {
+			if present[channel] {
+				ordered = append(ordered, channel)
+			}
+		}
+		return ordered
+	}
+}
+
+// groupByChannel collects messages into a batch per channel, preserving the
+// order of messages within each channel, and returns the channels in the
+// order they were first seen.
+func groupByChannel(ms []Message) (map[Channel][]Message, []Channel) {
+	batches := make(map[Channel][]Message)
+	channels := make([]Channel, 0)
+	for _, m := range ms {
+		if _, ok := batches[m.Channel]; !ok {
+			channels = append(channels, m.Channel)
+		}
+		batches[m.Channel] = append(batches[m.Channel], m)
+	}
+	return batches, channels
+}
+
+// splitBatch splits batch into chunks of at most n messages, or returns it
+// whole when n is not positive
+func splitBatch(batch []Message, n int) [][]Message {
+	if n <= 0 || len(batch) <= n {
+		return [][]Message{batch}
+	}
+	chunks := make([][]Message, 0, (len(batch)+n-1)/n)
+	for len(batch) > n {
+		chunks = append(chunks, batch[:n:n])
+		batch = batch[n:]
+	}
+	return append(chunks, batch)
+}
+
+// copiesFor returns the batch to send to each of n receivers. The copies are
+// all made before any is sent and the last receiver gets batch itself, so
+// that a receiver modifying its batch cannot race with another reading its
+// own.
+func copiesFor(batch []Message, n int) [][]Message {
+	copies := make([][]Message, n)
+	for i := 0; i < n-1; i++ {
+		copies[i] = append([]Message(nil), batch...)
+	}
+	if n > 0 {
+		copies[n-1] = batch
+	}
+	return copies
+}
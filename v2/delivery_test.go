@@ -0,0 +1,150 @@
+package gobayeux
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDeliveryOrderPolicies(t *testing.T) {
+	channels := []Channel{"/foo/a", "/foo/b", "/foo/c"}
+
+	testCases := []struct {
+		name   string
+		policy DeliveryOrderPolicy
+		want   [][]Channel
+	}{
+		{
+			"first seen",
+			FirstSeenDeliveryOrder(),
+			[][]Channel{
+				{"/foo/a", "/foo/b", "/foo/c"},
+				{"/foo/a", "/foo/b", "/foo/c"},
+			},
+		},
+		{
+			"round robin",
+			RoundRobinDeliveryOrder(),
+			[][]Channel{
+				{"/foo/a", "/foo/b", "/foo/c"},
+				{"/foo/b", "/foo/c", "/foo/a"},
+				{"/foo/c", "/foo/a", "/foo/b"},
+				{"/foo/a", "/foo/b", "/foo/c"},
+			},
+		},
+		{
+			"priority",
+			PriorityDeliveryOrder("/foo/c", "/foo/unknown", "/foo/b"),
+			[][]Channel{
+				{"/foo/c", "/foo/b", "/foo/a"},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			for i, want := range tc.want {
+				if got := tc.policy(channels); !reflect.DeepEqual(want, got) {
+					t.Errorf("response %d: want %v, got %v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupByChannel(t *testing.T) {
+	ms := []Message{
+		{Channel: "/foo/a", ID: "1"},
+		{Channel: "/foo/b", ID: "2"},
+		{Channel: "/foo/a", ID: "3"},
+	}
+
+	batches, channels := groupByChannel(ms)
+	if want := []Channel{"/foo/a", "/foo/b"}; !reflect.DeepEqual(want, channels) {
+		t.Errorf("expected channels %v, got %v", want, channels)
+	}
+	if got := batches["/foo/a"]; len(got) != 2 || got[0].ID != "1" || got[1].ID != "3" {
+		t.Errorf("expected /foo/a batch to hold messages 1 and 3 in order, got %v", got)
+	}
+	if got := batches["/foo/b"]; len(got) != 1 || got[0].ID != "2" {
+		t.Errorf("expected /foo/b batch to hold message 2, got %v", got)
+	}
+}
+
+func TestSplitBatch(t *testing.T) {
+	batch := []Message{{ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "4"}, {ID: "5"}}
+	testCases := []struct {
+		name string
+		n    int
+		want []int
+	}{
+		{"no limit", 0, []int{5}},
+		{"limit above size", 10, []int{5}},
+		{"limit equals size", 5, []int{5}},
+		{"even split", 1, []int{1, 1, 1, 1, 1}},
+		{"uneven split", 2, []int{2, 2, 1}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			chunks := splitBatch(batch, tc.n)
+			var sizes []int
+			var ids []string
+			for _, chunk := range chunks {
+				sizes = append(sizes, len(chunk))
+				for _, m := range chunk {
+					ids = append(ids, m.ID)
+				}
+			}
+			if !reflect.DeepEqual(sizes, tc.want) {
+				t.Errorf("want chunks of %v, got %v", tc.want, sizes)
+			}
+			if !reflect.DeepEqual(ids, []string{"1", "2", "3", "4", "5"}) {
+				t.Errorf("expected every message in order, got %v", ids)
+			}
+		})
+	}
+}
+
+func TestCopiesFor(t *testing.T) {
+	batch := []Message{{Channel: "/foo/bar"}, {Channel: "/foo/baz"}}
+	copies := copiesFor(batch, 3)
+	if len(copies) != 3 {
+		t.Fatalf("expected 3 batches, got %d", len(copies))
+	}
+	if &copies[2][0] != &batch[0] {
+		t.Error("expected the last receiver to get the batch itself")
+	}
+	copies[0][0].Channel = "/modified"
+	for i, c := range copies[1:] {
+		if !reflect.DeepEqual(c, batch) || c[0].Channel != "/foo/bar" {
+			t.Errorf("expected batch %d to be unaffected, got %v", i+1, c)
+		}
+	}
+	if copies := copiesFor(batch, 0); len(copies) != 0 {
+		t.Errorf("expected no batches without receivers, got %v", copies)
+	}
+}
+
+func TestForwardReceiversMayModifyTheirBatch(t *testing.T) {
+	ms := make(chan []Message)
+	first := make(chan []Message, 1)
+	second := make(chan []Message, 1)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go forward(&wg, done, ms, []chan []Message{first, second}, nil)
+	defer wg.Wait()
+	defer close(done)
+
+	// Run with -race: the first receiver overwrites its batch while the
+	// second is still being sent its own
+	ms <- []Message{{Channel: "/foo/bar"}}
+	batch := <-first
+	batch[0] = Message{Channel: "/modified"}
+	if got := <-second; got[0].Channel != "/foo/bar" {
+		t.Errorf("expected the second receiver's batch to be unaffected, got %v", got)
+	}
+}
@@ -0,0 +1,121 @@
+package gobayeux
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// DryRunTransport is an http.RoundTripper which records the messages sent
+// with every request instead of sending them to a server. Each request is answered with a
+// minimal successful response so that a BayeuxClient can proceed through the
+// handshake, connect, and subscribe flow. This makes it possible to assert on
+// the exact bytes that would be sent, e.g., in golden file tests.
+//
+//	transport := &gobayeux.DryRunTransport{}
+//	client, _ := gobayeux.NewBayeuxClient(nil, transport, serverAddress, nil)
+//	client.Handshake(ctx)
+//	client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar"})
+//	requests := transport.Requests()
+type DryRunTransport struct {
+	// ClientID is the clientId returned in response to a handshake. If it
+	// is empty, "dry-run" is used.
+	ClientID string
+
+	mu       sync.Mutex
+	requests [][]byte
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *DryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestMessages(req)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.requests = append(t.requests, body)
+	t.mu.Unlock()
+
+	var ms []Message
+	if err := json.Unmarshal(body, &ms); err != nil {
+		return nil, err
+	}
+
+	clientID := t.ClientID
+	if clientID == "" {
+		clientID = "dry-run"
+	}
+
+	replies := make([]Message, 0, len(ms))
+	for _, m := range ms {
+		reply := Message{
+			Channel:      m.Channel,
+			ID:           m.ID,
+			ClientID:     m.ClientID,
+			Subscription: m.Subscription,
+			Successful:   true,
+		}
+		if m.Channel == MetaHandshake {
+			reply.ClientID = clientID
+			reply.Version = m.Version
+			reply.SupportedConnectionTypes = m.SupportedConnectionTypes
+		}
+		replies = append(replies, reply)
+	}
+
+	encoded, err := json.Marshal(replies)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := "application/json"
+	if callback := req.URL.Query().Get("jsonp"); callback != "" {
+		contentType = "application/javascript"
+		encoded = []byte(callback + "(" + string(encoded) + ")")
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Header:     http.Header{"Content-Type": []string{contentType}},
+		Body:       io.NopCloser(bytes.NewReader(encoded)),
+		Request:    req,
+	}, nil
+}
+
+// requestMessages returns the encoded messages sent with req. Callback-polling
+// sends them in the "message" query parameter and EncodingForm in the
+// "message" field of the body rather than as the body itself.
+func requestMessages(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return []byte(req.URL.Query().Get("message")), nil
+	}
+	defer req.Body.Close()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType != formMediaType {
+		return body, nil
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(form.Get("message")), nil
+}
+
+// Requests returns the encoded messages of every request seen so far, in the
+// order they were made
+func (t *DryRunTransport) Requests() [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	requests := make([][]byte, len(t.requests))
+	copy(requests, t.requests)
+	return requests
+}
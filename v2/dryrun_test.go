@@ -0,0 +1,105 @@
+package gobayeux_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestDryRunTransport(t *testing.T) {
+	transport := &gobayeux.DryRunTransport{ClientID: "Un1q31d3nt1f13r"}
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar", "/foo/baz"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+
+	want := []string{
+		`[{"id":"1","channel":"/meta/handshake","version":"1.0","minimumVersion":"1.0","supportedConnectionTypes":["long-polling"]}]`,
+		`[{"id":"2","channel":"/meta/subscribe","clientId":"Un1q31d3nt1f13r","subscription":"/foo/bar"},{"id":"3","channel":"/meta/subscribe","clientId":"Un1q31d3nt1f13r","subscription":"/foo/baz"}]`,
+		`[{"id":"4","channel":"/meta/connect","clientId":"Un1q31d3nt1f13r","connectionType":"long-polling"}]`,
+	}
+
+	got := transport.Requests()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d requests, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Errorf("request %d: want\n%s\ngot\n%s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestDryRunTransportCallbackPolling(t *testing.T) {
+	transport := &gobayeux.DryRunTransport{ClientID: "Un1q31d3nt1f13r"}
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil, gobayeux.WithCallbackPolling())
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+
+	want := []string{
+		`[{"id":"1","channel":"/meta/handshake","version":"1.0","minimumVersion":"1.0","supportedConnectionTypes":["callback-polling"]}]`,
+		`[{"id":"2","channel":"/meta/connect","clientId":"Un1q31d3nt1f13r","connectionType":"callback-polling"}]`,
+	}
+
+	got := transport.Requests()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d requests, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Errorf("request %d: want\n%s\ngot\n%s", i, want[i], got[i])
+		}
+	}
+}
+
+func TestDryRunTransportFormEncoding(t *testing.T) {
+	transport := &gobayeux.DryRunTransport{ClientID: "Un1q31d3nt1f13r"}
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil, gobayeux.WithHTTPEncoding(gobayeux.EncodingForm))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+
+	want := []string{
+		`[{"id":"1","channel":"/meta/handshake","version":"1.0","minimumVersion":"1.0","supportedConnectionTypes":["long-polling"]}]`,
+		`[{"id":"2","channel":"/meta/connect","clientId":"Un1q31d3nt1f13r","connectionType":"long-polling"}]`,
+	}
+
+	got := transport.Requests()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d requests, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if string(got[i]) != want[i] {
+			t.Errorf("request %d: want\n%s\ngot\n%s", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package gobayeux
+
+import "net/url"
+
+// HTTPEncoding determines how the messages of each request are put in the
+// body of the HTTP request
+type HTTPEncoding int
+
+const (
+	// EncodingJSON sends the encoded messages as the request body. This is
+	// the default.
+	EncodingJSON HTTPEncoding = iota
+	// EncodingForm sends the encoded messages in the "message" field of an
+	// application/x-www-form-urlencoded body, as expected by some older
+	// servers such as the Dojo CometD server
+	EncodingForm
+)
+
+// formMediaType is the Content-Type of requests sent with EncodingForm
+const formMediaType = "application/x-www-form-urlencoded"
+
+// WithHTTPEncoding returns an Option which puts the messages of each request
+// in the HTTP request body as described by encoding
+func WithHTTPEncoding(encoding HTTPEncoding) Option {
+	return func(options *Options) {
+		options.HTTPEncoding = encoding
+	}
+}
+
+// encode returns the request body for the encoded messages and its
+// Content-Type
+func (e HTTPEncoding) encode(body []byte, mediaType string) ([]byte, string) {
+	if e != EncodingForm {
+		return body, mediaType
+	}
+	form := url.Values{"message": []string{string(body)}}
+	return []byte(form.Encode()), formMediaType
+}
@@ -0,0 +1,68 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestWithHTTPEncoding(t *testing.T) {
+	testCases := []struct {
+		name        string
+		encoding    gobayeux.HTTPEncoding
+		contentType string
+	}{
+		{"json", gobayeux.EncodingJSON, "application/json"},
+		{"form", gobayeux.EncodingForm, "application/x-www-form-urlencoded"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			var sent []gobayeux.Message
+			transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+				if got := r.Header.Get("Content-Type"); got != tc.contentType {
+					t.Errorf("expected Content-Type %q, got %q", tc.contentType, got)
+				}
+
+				var body []byte
+				if tc.encoding == gobayeux.EncodingForm {
+					if err := r.ParseForm(); err != nil {
+						return nil, err
+					}
+					body = []byte(r.PostForm.Get("message"))
+				} else {
+					var err error
+					if body, err = io.ReadAll(r.Body); err != nil {
+						return nil, err
+					}
+				}
+				if err := json.Unmarshal(body, &sent); err != nil {
+					t.Errorf("expected the messages to be JSON, got %q (%v)", body, err)
+				}
+
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Status:     http.StatusText(http.StatusOK),
+					Body:       io.NopCloser(bytes.NewBufferString(`[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`)),
+				}, nil
+			})
+
+			client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil, gobayeux.WithHTTPEncoding(tc.encoding))
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			if _, err := client.Handshake(context.Background()); err != nil {
+				t.Fatalf("failed to handshake (%v)", err)
+			}
+			if len(sent) != 1 || sent[0].Channel != gobayeux.MetaHandshake {
+				t.Errorf("expected a handshake to be sent, got %v", sent)
+			}
+		})
+	}
+}
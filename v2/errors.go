@@ -1,7 +1,11 @@
 package gobayeux
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 const (
@@ -29,6 +33,51 @@ const (
 
 	// ErrMissingConnectionType is returned when the connection type is unset
 	ErrMissingConnectionType = sentinel("missing connectionType value")
+
+	// ErrAlreadySubscribed is returned when subscribing to a channel which
+	// already has a subscription
+	ErrAlreadySubscribed = sentinel("already subscribed")
+
+	// ErrNotSubscribed is returned when messages arrive on a channel which
+	// has no subscription
+	ErrNotSubscribed = sentinel("not subscribed")
+
+	// ErrClientClosed is returned when using a Client after it has been
+	// disconnected
+	ErrClientClosed = sentinel("client has been disconnected")
+
+	// ErrMultipleClients is reported when the server advises that it has
+	// seen the client's session on more than one connection. The Client
+	// handshakes again to start a session of its own.
+	ErrMultipleClients = sentinel("server detected multiple clients using the session")
+
+	// ErrTLSConfigUnsupported is returned when a TLS configuration is given
+	// but the transport is not an *http.Transport
+	ErrTLSConfigUnsupported = sentinel("TLS configuration requires an *http.Transport")
+
+	// ErrTransportTuningUnsupported is returned when connection tuning such
+	// as WithIdleConnTimeout is given but the transport is not an
+	// *http.Transport
+	ErrTransportTuningUnsupported = sentinel("connection tuning requires an *http.Transport")
+
+	// ErrBadCallbackResponse is returned when a callback-polling response is
+	// not a call to the requested JSONP callback
+	ErrBadCallbackResponse = sentinel("callback-polling response is not a JSONP callback")
+
+	// ErrStaleSession is returned when the response to a request arrives
+	// after the client has re-handshaken and been given a new clientID. The
+	// response belongs to the old session so it is dropped.
+	ErrStaleSession = sentinel("response belongs to a replaced session")
+
+	// ErrStaleConnect is returned when the response to a /meta/connect
+	// arrives after another /meta/connect was sent, or its reply echoes the
+	// id of a different request. Only the latest /meta/connect is answered
+	// so its reply is dropped, any events sent with it are still returned.
+	ErrStaleConnect = sentinel("response is not for the latest /meta/connect")
+
+	// ErrNoPublishReply is returned when the server's response to a publish
+	// does not include a reply to the published message
+	ErrNoPublishReply = sentinel("server did not reply to the published message")
 )
 
 type sentinel string
@@ -63,8 +112,8 @@ func (e HandshakeFailedError) Unwrap() error {
 	return e.Err
 }
 
-func newHandshakeError(msg string) *HandshakeFailedError {
-	return &HandshakeFailedError{
+func newHandshakeError(msg string) HandshakeFailedError {
+	return HandshakeFailedError{
 		fmt.Errorf("handshake was not successful: %s", msg),
 	}
 }
@@ -73,6 +122,10 @@ func newHandshakeError(msg string) *HandshakeFailedError {
 type SubscriptionFailedError struct {
 	Channels []Channel
 	Err      error
+	// Failed holds the error for each channel the server rejected. It is nil
+	// when the request as a whole failed, in which case none of the Channels
+	// were subscribed to.
+	Failed map[Channel]error
 }
 
 func (e SubscriptionFailedError) Error() string {
@@ -83,6 +136,33 @@ func (e SubscriptionFailedError) Unwrap() error {
 	return e.Err
 }
 
+// Succeeded returns the Channels which the server accepted despite the
+// request failing for others
+func (e SubscriptionFailedError) Succeeded() []Channel {
+	if e.Failed == nil {
+		return nil
+	}
+
+	succeeded := make([]Channel, 0, len(e.Channels))
+	for _, channel := range e.Channels {
+		if _, failed := e.Failed[channel]; !failed {
+			succeeded = append(succeeded, channel)
+		}
+	}
+	return succeeded
+}
+
+// AuthorizationError is returned for a channel the server's ACL probe
+// denied before it was subscribed to
+type AuthorizationError struct {
+	Channel Channel
+	Message string
+}
+
+func (e AuthorizationError) Error() string {
+	return fmt.Sprintf("not authorized to subscribe to %q: %s", e.Channel, e.Message)
+}
+
 // UnsubscribeFailedError is returned for any errors on Unsubscribe
 type UnsubscribeFailedError struct {
 	Channels []Channel
@@ -97,6 +177,20 @@ func (e UnsubscribeFailedError) Unwrap() error {
 	return e.Err
 }
 
+// PublishFailedError is returned when publishing to Channel fails
+type PublishFailedError struct {
+	Channel Channel
+	Err     error
+}
+
+func (e PublishFailedError) Error() string {
+	return fmt.Sprintf("publish to %s failed (%s)", e.Channel, e.Err)
+}
+
+func (e PublishFailedError) Unwrap() error {
+	return e.Err
+}
+
 // ActionFailedError is a general purpose error returned by the BayeuxClient
 type ActionFailedError struct {
 	Action       string
@@ -107,12 +201,16 @@ func (e ActionFailedError) Error() string {
 	return fmt.Sprintf("unable to %s channels: %s", e.Action, e.ErrorMessage)
 }
 
-func newSubscribeError(msg string) *ActionFailedError {
-	return &ActionFailedError{"subscribe to", msg}
+func newSubscribeError(msg string) ActionFailedError {
+	return ActionFailedError{"subscribe to", msg}
+}
+
+func newUnsubscribeError(msg string) ActionFailedError {
+	return ActionFailedError{"unsubscribe from", msg}
 }
 
-func newUnsubscribeError(msg string) *ActionFailedError {
-	return &ActionFailedError{"unsubscribe from", msg}
+func newPublishError(msg string) ActionFailedError {
+	return ActionFailedError{"publish to", msg}
 }
 
 // DisconnectFailedError is returned when the call to Disconnect fails
@@ -144,19 +242,98 @@ func (e AlreadyRegisteredError) Error() string {
 	return fmt.Sprintf("extension already registered: %s", e.MessageExtender)
 }
 
+// NotRegisteredError signifies that the given MessageExtender is not
+// registered with the client
+type NotRegisteredError struct {
+	MessageExtender
+}
+
+func (e NotRegisteredError) Error() string {
+	return fmt.Sprintf("extension not registered: %s", e.MessageExtender)
+}
+
+// ExtensionError is returned when a ContextMessageExtender fails a request
+// while handling a message on the given channel
+type ExtensionError struct {
+	Channel  Channel
+	Outgoing bool
+	Err      error
+}
+
+func (e ExtensionError) Error() string {
+	direction := "incoming"
+	if e.Outgoing {
+		direction = "outgoing"
+	}
+	return fmt.Sprintf("extension failed %s message on %s (%s)", direction, e.Channel, e.Err)
+}
+
+func (e ExtensionError) Unwrap() error {
+	return e.Err
+}
+
 // BadResponseError is returned when we get an unexpected HTTP response from the server
 type BadResponseError struct {
 	StatusCode int
 	Status     string
 	Body       []byte
+	// Operation is the kind of request (see the Operation constants) the
+	// server responded to
+	Operation string
 }
 
+// badResponseBodyPreview is how much of the body BadResponseError.Error
+// includes
+const badResponseBodyPreview = 200
+
 func (e BadResponseError) Error() string {
-	return fmt.Sprintf(
-		"expected 200 response from bayeux server, got %d with status '%s'",
-		e.StatusCode,
-		e.Status,
-	)
+	operation := e.Operation
+	if operation == "" {
+		operation = "request"
+	}
+
+	// http.Response.Status already starts with the code, e.g. "403 Forbidden"
+	status := e.Status
+	if !strings.HasPrefix(status, strconv.Itoa(e.StatusCode)) {
+		status = strings.TrimSpace(fmt.Sprintf("%d %s", e.StatusCode, status))
+	}
+
+	msg := fmt.Sprintf("%s failed: %s", operation, status)
+	if preview := strings.Join(strings.Fields(string(e.Body)), " "); preview != "" {
+		if len(preview) > badResponseBodyPreview {
+			cut := badResponseBodyPreview
+			for cut > 0 && !utf8.RuneStart(preview[cut]) {
+				cut--
+			}
+			preview = preview[:cut] + "..."
+		}
+		msg += ": " + preview
+	}
+	return msg
+}
+
+// ResponseTooLargeError is returned when the body of a response to an
+// operation is larger than the limit set with WithMaxResponseBytes
+type ResponseTooLargeError struct {
+	Limit     int64
+	Operation string
+}
+
+func (e ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("%s response is larger than %d bytes", e.Operation, e.Limit)
+}
+
+// RehandshakeNotice is reported, with WithRehandshakeNotices, on the channel
+// returned by Client.Start whenever the Client discards its session to
+// handshake again, e.g., as the server advised. It is not fatal; the Client
+// keeps running.
+type RehandshakeNotice struct {
+	// ClientID identifies the session that was discarded
+	ClientID string
+}
+
+func (e RehandshakeNotice) Error() string {
+	return fmt.Sprintf("re-handshaking, session %s was discarded", e.ClientID)
 }
 
 // BadConnectionTypeError is returned when we don't know how to handle the
@@ -169,6 +346,27 @@ func (e BadConnectionTypeError) Error() string {
 	return fmt.Sprintf("%q is not a valid connection type", e.ConnectionType)
 }
 
+// UnsupportedConnectionTypeError is returned when the server's handshake
+// response does not list the connection type the client uses
+type UnsupportedConnectionTypeError struct {
+	ConnectionType string
+	Supported      []string
+}
+
+func (e UnsupportedConnectionTypeError) Error() string {
+	return fmt.Sprintf("server does not support the %q connection type, only %q", e.ConnectionType, e.Supported)
+}
+
+// BadSchemeError is returned when the server address does not use one of
+// the http, https, ws or wss schemes
+type BadSchemeError struct {
+	Scheme string
+}
+
+func (e BadSchemeError) Error() string {
+	return fmt.Sprintf("server address scheme %q is not supported, use http or https", e.Scheme)
+}
+
 // BadConnectionVersionError is returned when we can't support the requested
 // version number
 type BadConnectionVersionError struct {
@@ -219,6 +417,10 @@ type BadHandshakeError struct {
 	*BadStateError
 }
 
+func (e BadHandshakeError) Unwrap() error {
+	return e.BadStateError
+}
+
 func newBadHanshake(current, from, to int32) *BadHandshakeError {
 	return &BadHandshakeError{
 		&BadStateError{
@@ -235,6 +437,10 @@ type BadConnectionError struct {
 	*BadStateError
 }
 
+func (e BadConnectionError) Unwrap() error {
+	return e.BadStateError
+}
+
 func newBadConnection(current, from, to int32) *BadConnectionError {
 	return &BadConnectionError{
 		&BadStateError{
@@ -254,3 +460,15 @@ type UnknownEventTypeError struct {
 func (e UnknownEventTypeError) Error() string {
 	return fmt.Sprintf("unknown event type (%q)", e.Event)
 }
+
+// isNonFatal reports whether err only affects the channels involved, i.e.,
+// the server answered but rejected them or they were already subscribed,
+// rather than the connection to the server
+func isNonFatal(err error) bool {
+	var subErr SubscriptionFailedError
+	if errors.As(err, &subErr) && subErr.Failed != nil {
+		return true
+	}
+	var actionErr ActionFailedError
+	return errors.As(err, &actionErr) || errors.Is(err, ErrAlreadySubscribed)
+}
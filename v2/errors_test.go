@@ -0,0 +1,206 @@
+package gobayeux_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+func TestBadResponseError_Error(t *testing.T) {
+	testCases := []struct {
+		name string
+		err  gobayeux.BadResponseError
+		want string
+	}{
+		{
+			name: "status from http.Response with a body",
+			err: gobayeux.BadResponseError{
+				StatusCode: http.StatusForbidden,
+				Status:     "403 Forbidden",
+				Body:       []byte("{\"error\":\"403::denied\"}\n"),
+				Operation:  gobayeux.OperationSubscribe,
+			},
+			want: `subscribe failed: 403 Forbidden: {"error":"403::denied"}`,
+		},
+		{
+			name: "status text without the code",
+			err: gobayeux.BadResponseError{
+				StatusCode: http.StatusBadGateway,
+				Status:     http.StatusText(http.StatusBadGateway),
+				Operation:  gobayeux.OperationConnect,
+			},
+			want: "connect failed: 502 Bad Gateway",
+		},
+		{
+			name: "no operation or status",
+			err:  gobayeux.BadResponseError{StatusCode: http.StatusTeapot},
+			want: "request failed: 418",
+		},
+		{
+			name: "long body is truncated",
+			err: gobayeux.BadResponseError{
+				StatusCode: http.StatusInternalServerError,
+				Status:     "500 Internal Server Error",
+				Body:       []byte(strings.Repeat("x", 300)),
+				Operation:  gobayeux.OperationHandshake,
+			},
+			want: "handshake failed: 500 Internal Server Error: " + strings.Repeat("x", 200) + "...",
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.err.Error(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBadResponseError_As(t *testing.T) {
+	err := gobayeux.ConnectionFailedError{Err: fmt.Errorf("wrapped: %w", gobayeux.BadResponseError{StatusCode: http.StatusUnauthorized})}
+
+	var badResponse gobayeux.BadResponseError
+	if !errors.As(err, &badResponse) || badResponse.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected to find the BadResponseError, got %v", err)
+	}
+}
+
+func TestErrorChains(t *testing.T) {
+	newClient := func(t *testing.T, transport http.RoundTripper) *gobayeux.BayeuxClient {
+		t.Helper()
+		client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil)
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		return client
+	}
+	newServer := func(t *testing.T) *gobayeuxtest.Server {
+		t.Helper()
+		server := gobayeuxtest.NewServer(t)
+		if err := server.Start(context.Background()); err != nil {
+			t.Fatalf("failed to start test server (%v)", err)
+		}
+		return server
+	}
+	rejectingHandshake := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(strings.NewReader(`[{"channel":"/meta/handshake","successful":false,"error":"401::unauthorized"}]`)),
+		}, nil
+	})
+	ctx := context.Background()
+
+	testCases := []struct {
+		name string
+		run  func(t *testing.T) error
+		as   []any
+		is   error
+	}{
+		{
+			name: "subscribe before handshake",
+			run: func(t *testing.T) error {
+				_, err := newClient(t, newServer(t)).Subscribe(ctx, []gobayeux.Channel{"/foo/bar"})
+				return err
+			},
+			as: []any{new(gobayeux.SubscriptionFailedError)},
+			is: gobayeux.ErrClientNotConnected,
+		},
+		{
+			name: "unsubscribe before handshake",
+			run: func(t *testing.T) error {
+				_, err := newClient(t, newServer(t)).Unsubscribe(ctx, []gobayeux.Channel{"/foo/bar"})
+				return err
+			},
+			as: []any{new(gobayeux.UnsubscribeFailedError)},
+			is: gobayeux.ErrClientNotConnected,
+		},
+		{
+			name: "disconnect before handshake",
+			run: func(t *testing.T) error {
+				_, err := newClient(t, newServer(t)).Disconnect(ctx)
+				return err
+			},
+			as: []any{new(gobayeux.DisconnectFailedError)},
+			is: gobayeux.ErrClientNotConnected,
+		},
+		{
+			name: "rejected handshake",
+			run: func(t *testing.T) error {
+				_, err := newClient(t, rejectingHandshake).Handshake(ctx)
+				return err
+			},
+			as: []any{new(gobayeux.HandshakeFailedError)},
+		},
+		{
+			name: "handshake in the wrong state",
+			run: func(t *testing.T) error {
+				client := newClient(t, newServer(t))
+				if _, err := client.Handshake(ctx); err != nil {
+					t.Fatalf("failed to handshake (%v)", err)
+				}
+				_, err := client.Handshake(ctx)
+				return err
+			},
+			as: []any{new(gobayeux.HandshakeFailedError), new(*gobayeux.BadHandshakeError), new(*gobayeux.BadStateError)},
+		},
+		{
+			name: "subscription denied",
+			run: func(t *testing.T) error {
+				server := newServer(t)
+				server.Deny("/foo/denied")
+				client := newClient(t, server)
+				if _, err := client.Handshake(ctx); err != nil {
+					t.Fatalf("failed to handshake (%v)", err)
+				}
+				_, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/denied"})
+				return err
+			},
+			as: []any{new(gobayeux.SubscriptionFailedError), new(gobayeux.ActionFailedError)},
+		},
+		{
+			name: "bad response on connect",
+			run: func(t *testing.T) error {
+				return gobayeux.ConnectionFailedError{Err: gobayeux.BadResponseError{StatusCode: http.StatusBadGateway}}
+			},
+			as: []any{new(gobayeux.ConnectionFailedError), new(gobayeux.BadResponseError)},
+		},
+		{
+			name: "extension registered twice",
+			run: func(t *testing.T) error {
+				client := newClient(t, newServer(t))
+				ext := gobayeuxtest.NewReplayExtension()
+				if err := client.UseExtension(ext); err != nil {
+					t.Fatalf("failed to register extension (%v)", err)
+				}
+				return client.UseExtension(ext)
+			},
+			as: []any{new(gobayeux.AlreadyRegisteredError)},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			// Wrapping once more shows the chain is followed at any depth
+			err := fmt.Errorf("client failed: %w", tc.run(t))
+			for _, target := range tc.as {
+				if !errors.As(err, target) {
+					t.Errorf("expected errors.As to find a %T in %v", target, err)
+				}
+			}
+			if tc.is != nil && !errors.Is(err, tc.is) {
+				t.Errorf("expected errors.Is to find %v in %v", tc.is, err)
+			}
+		})
+	}
+}
@@ -1,5 +1,7 @@
 package gobayeux
 
+import "context"
+
 // MessageExtender defines the interface that extensions are expected to
 // implement
 type MessageExtender interface {
@@ -8,3 +10,73 @@ type MessageExtender interface {
 	Registered(extensionName string, client *BayeuxClient)
 	Unregistered()
 }
+
+// ContextMessageExtender may be implemented by a MessageExtender that needs
+// the context of the request a message belongs to, e.g., to read its
+// RequestInfo with RequestInfoFromContext, or that needs to halt a request.
+// When an extension implements it OutgoingContext and IncomingContext are
+// called instead of Outgoing and Incoming.
+//
+// An error returned by OutgoingContext stops the request before it is sent
+// and an error returned by IncomingContext fails it once the response has
+// been read. Either is returned to the caller wrapped in an ExtensionError.
+type ContextMessageExtender interface {
+	MessageExtender
+	OutgoingContext(context.Context, *Message) error
+	IncomingContext(context.Context, *Message) error
+}
+
+// AdaptExtender returns a ContextMessageExtender which calls the Outgoing
+// and Incoming methods of ext and never fails. It returns ext unchanged when
+// it already implements ContextMessageExtender.
+func AdaptExtender(ext MessageExtender) ContextMessageExtender {
+	if ce, ok := ext.(ContextMessageExtender); ok {
+		return ce
+	}
+	return extenderAdapter{ext}
+}
+
+type extenderAdapter struct {
+	MessageExtender
+}
+
+func (a extenderAdapter) OutgoingContext(_ context.Context, m *Message) error {
+	a.Outgoing(m)
+	return nil
+}
+
+func (a extenderAdapter) IncomingContext(_ context.Context, m *Message) error {
+	a.Incoming(m)
+	return nil
+}
+
+// MessageInterceptor inspects a whole batch of messages and returns the
+// batch to use in its place. Unlike a MessageExtender it may add, remove or
+// reorder messages. A returned error fails the request.
+type MessageInterceptor func(context.Context, []Message) ([]Message, error)
+
+// extendOutgoing runs every extension over the messages about to be sent
+func extendOutgoing(ctx context.Context, exts []MessageExtender, ms []Message) error {
+	for _, ext := range exts {
+		ce := AdaptExtender(ext)
+		for i := range ms {
+			if err := ce.OutgoingContext(ctx, &ms[i]); err != nil {
+				return ExtensionError{Channel: ms[i].Channel, Outgoing: true, Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// extendIncoming runs every extension over the messages received
+func extendIncoming(ctx context.Context, exts []MessageExtender, ms []Message) error {
+	for _, ext := range exts {
+		ce := AdaptExtender(ext)
+		for i := range ms {
+			if err := ce.IncomingContext(ctx, &ms[i]); err != nil {
+				return ExtensionError{Channel: ms[i].Channel, Err: err}
+			}
+		}
+	}
+	return nil
+}
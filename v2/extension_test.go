@@ -0,0 +1,429 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+var errExpiredToken = errors.New("token expired")
+
+type failingExtension struct {
+	outgoing, incoming bool
+}
+
+func (e *failingExtension) Outgoing(*gobayeux.Message)                {}
+func (e *failingExtension) Incoming(*gobayeux.Message)                {}
+func (e *failingExtension) Registered(string, *gobayeux.BayeuxClient) {}
+func (e *failingExtension) Unregistered()                             {}
+
+func (e *failingExtension) OutgoingContext(_ context.Context, m *gobayeux.Message) error {
+	if e.outgoing {
+		return errExpiredToken
+	}
+	return nil
+}
+
+func (e *failingExtension) IncomingContext(_ context.Context, m *gobayeux.Message) error {
+	if e.incoming {
+		return errExpiredToken
+	}
+	return nil
+}
+
+func TestExtensionErrorFailsRequest(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ext      *failingExtension
+		sent     int
+		outgoing bool
+	}{
+		{"outgoing", &failingExtension{outgoing: true}, 0, true},
+		{"incoming", &failingExtension{incoming: true}, 1, false},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			server := gobayeuxtest.NewServer(t)
+			if err := server.Start(context.Background()); err != nil {
+				t.Fatalf("failed to start test server (%v)", err)
+			}
+
+			sent := 0
+			transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+				sent++
+				return server.RoundTrip(r)
+			})
+			client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			if err := client.UseExtension(tc.ext); err != nil {
+				t.Fatalf("failed to register extension (%v)", err)
+			}
+
+			_, err = client.Handshake(context.Background())
+			if !errors.Is(err, errExpiredToken) {
+				t.Fatalf("expected the extension's error, got %v", err)
+			}
+			var extErr gobayeux.ExtensionError
+			if !errors.As(err, &extErr) {
+				t.Fatalf("expected an ExtensionError, got %T", err)
+			}
+			if extErr.Channel != gobayeux.MetaHandshake || extErr.Outgoing != tc.outgoing {
+				t.Errorf("unexpected ExtensionError %+v", extErr)
+			}
+			if sent != tc.sent {
+				t.Errorf("expected %d requests to be sent, got %d", tc.sent, sent)
+			}
+		})
+	}
+}
+
+type legacyExtension struct {
+	outgoing, incoming, unregistered int
+}
+
+func (e *legacyExtension) Outgoing(*gobayeux.Message)                { e.outgoing++ }
+func (e *legacyExtension) Incoming(*gobayeux.Message)                { e.incoming++ }
+func (e *legacyExtension) Registered(string, *gobayeux.BayeuxClient) {}
+func (e *legacyExtension) Unregistered()                             { e.unregistered++ }
+
+func TestAdaptExtender(t *testing.T) {
+	legacy := &legacyExtension{}
+	adapted := gobayeux.AdaptExtender(legacy)
+
+	m := &gobayeux.Message{Channel: gobayeux.MetaConnect}
+	if err := adapted.OutgoingContext(context.Background(), m); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := adapted.IncomingContext(context.Background(), m); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if legacy.outgoing != 1 || legacy.incoming != 1 {
+		t.Errorf("expected each hook to be called once, got %d outgoing and %d incoming", legacy.outgoing, legacy.incoming)
+	}
+
+	failing := &failingExtension{}
+	if got := gobayeux.AdaptExtender(failing); got != gobayeux.ContextMessageExtender(failing) {
+		t.Errorf("expected a ContextMessageExtender to be returned unchanged, got %v", got)
+	}
+}
+
+type stampExtension struct{}
+
+func (stampExtension) Outgoing(m *gobayeux.Message) {
+	m.GetExt(true)["stamp"] = string(m.Channel)
+}
+func (stampExtension) Incoming(*gobayeux.Message)                {}
+func (stampExtension) Registered(string, *gobayeux.BayeuxClient) {}
+func (stampExtension) Unregistered()                             {}
+
+func TestOutgoingExtensionChangesAreSent(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	var sent []gobayeux.Message
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.Unmarshal(body, &ms); err != nil {
+			return nil, err
+		}
+		sent = append(sent, ms...)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if err := client.UseExtension(stampExtension{}); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar", "/foo/baz"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+
+	if len(sent) != 3 {
+		t.Fatalf("expected 3 messages to be sent, got %d", len(sent))
+	}
+	for _, m := range sent {
+		if stamp, _ := m.Ext["stamp"].(string); stamp != string(m.Channel) {
+			t.Errorf("expected the %s message to be stamped, got ext %v", m.Channel, m.Ext)
+		}
+	}
+}
+
+type decodeExtension struct{}
+
+func (decodeExtension) Outgoing(*gobayeux.Message) {}
+func (decodeExtension) Incoming(m *gobayeux.Message) {
+	if m.Channel == gobayeux.MetaHandshake {
+		m.GetExt(true)["decoded"] = true
+	}
+}
+func (decodeExtension) Registered(string, *gobayeux.BayeuxClient) {}
+func (decodeExtension) Unregistered()                             {}
+
+func TestIncomingExtensionChangesAreReturned(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if err := client.UseExtension(decodeExtension{}); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ms, err := client.Handshake(context.Background())
+	if err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if len(ms) != 1 {
+		t.Fatalf("expected 1 handshake reply, got %d", len(ms))
+	}
+	if decoded, _ := ms[0].Ext["decoded"].(bool); !decoded {
+		t.Errorf("expected the extension's change to be returned, got ext %v", ms[0].Ext)
+	}
+}
+
+func TestRemoveExtension(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ext := &legacyExtension{}
+	if err := client.UseExtension(ext); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if err := client.RemoveExtension(ext); err != nil {
+		t.Fatalf("failed to remove extension (%v)", err)
+	}
+	if ext.unregistered != 1 {
+		t.Errorf("expected Unregistered to be called once, got %d", ext.unregistered)
+	}
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+	if ext.outgoing != 1 || ext.incoming != 1 {
+		t.Errorf("expected the extension to only run for the handshake, got %d outgoing and %d incoming", ext.outgoing, ext.incoming)
+	}
+
+	var notRegistered gobayeux.NotRegisteredError
+	if err := client.RemoveExtension(ext); !errors.As(err, &notRegistered) {
+		t.Errorf("expected a NotRegisteredError, got %v", err)
+	}
+}
+
+func TestExtensionsChangedDuringRequests(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	// The race detector flags any unsynchronised access to the extensions
+	// while requests run them
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			if _, err := client.Connect(ctx); err != nil {
+				t.Errorf("failed to connect (%v)", err)
+				return
+			}
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		ext := stampExtension{}
+		if err := client.UseExtension(ext); err != nil {
+			t.Fatalf("failed to register extension (%v)", err)
+		}
+		if err := client.RemoveExtension(ext); err != nil {
+			t.Fatalf("failed to remove extension (%v)", err)
+		}
+	}
+	<-done
+}
+
+func TestRequestInterceptor(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	var sent []gobayeux.Message
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		var ms []gobayeux.Message
+		if err := json.Unmarshal(body, &ms); err != nil {
+			return nil, err
+		}
+		sent = append(sent, ms...)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+
+	var seen []gobayeux.Message
+	reverse := func(_ context.Context, ms []gobayeux.Message) ([]gobayeux.Message, error) {
+		seen = append(seen, ms...)
+		out := make([]gobayeux.Message, 0, len(ms))
+		for i := len(ms) - 1; i >= 0; i-- {
+			out = append(out, ms[i])
+		}
+		return out, nil
+	}
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil,
+		gobayeux.WithRequestInterceptor(reverse),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if err := client.UseExtension(stampExtension{}); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar", "/foo/baz"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+
+	if len(seen) != 3 {
+		t.Fatalf("expected the interceptor to see 3 messages, got %d", len(seen))
+	}
+	for _, m := range seen {
+		if stamp, _ := m.Ext["stamp"].(string); stamp != string(m.Channel) {
+			t.Errorf("expected the interceptor to see the %s message after extensions, got ext %v", m.Channel, m.Ext)
+		}
+	}
+	if len(sent) != 3 || sent[1].Subscription != "/foo/baz" || sent[2].Subscription != "/foo/bar" {
+		t.Errorf("expected the subscribe batch to be sent reversed, got %v", sent)
+	}
+}
+
+func TestRequestInterceptorErrorAbortsRequest(t *testing.T) {
+	requests := 0
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		requests++
+		return nil, errors.New("unexpected request")
+	})
+	abort := func(context.Context, []gobayeux.Message) ([]gobayeux.Message, error) {
+		return nil, errExpiredToken
+	}
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil,
+		gobayeux.WithRequestInterceptor(abort),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	if _, err := client.Handshake(context.Background()); !errors.Is(err, errExpiredToken) {
+		t.Errorf("expected the interceptor's error, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("expected no request to be sent, got %d", requests)
+	}
+}
+
+func TestResponseInterceptor(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	extended := false
+	dropBaz := func(_ context.Context, ms []gobayeux.Message) ([]gobayeux.Message, error) {
+		var kept []gobayeux.Message
+		for _, m := range ms {
+			if _, ok := m.Ext["decoded"]; ok {
+				extended = true
+			}
+			if m.Channel != "/foo/baz" {
+				kept = append(kept, m)
+			}
+		}
+		return kept, nil
+	}
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil,
+		gobayeux.WithResponseInterceptor(dropBaz),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if err := client.UseExtension(decodeExtension{}); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if extended {
+		t.Error("expected the interceptor to run before the extensions")
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar", "/foo/baz"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	ms, err := client.Connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+
+	delivered := map[gobayeux.Channel]int{}
+	for _, m := range ms {
+		delivered[m.Channel]++
+	}
+	if delivered["/foo/bar"] == 0 {
+		t.Errorf("expected events on /foo/bar to be kept, got %v", ms)
+	}
+	if delivered["/foo/baz"] != 0 {
+		t.Errorf("expected events on /foo/baz to be dropped, got %v", ms)
+	}
+}
@@ -0,0 +1,116 @@
+// Package ack provides an implementation of the CometD acknowledgement
+// extension for the Bayeux protocol.
+//
+// With acknowledgements enabled the server numbers each /meta/connect
+// response it sends and the client echoes the last number it received on its
+// next /meta/connect request. This lets the server resend any messages the
+// client missed, e.g., because a response was lost, providing at-least-once
+// delivery across reconnects.
+//
+// Example Usage:
+//
+//	client := gobayeux.NewClient(serverAddress)
+//	client.UseExtension(ack.New())
+//
+// See also: https://docs.cometd.org/current/reference/#_extensions_acknowledge
+package ack
+
+import (
+	"encoding/json"
+	"sync/atomic"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+const (
+	// ExtensionName is the name used by CometD for the acknowledgement
+	// extension
+	ExtensionName string = "ack"
+
+	// noBatch is the ack id sent before any batch has been received
+	noBatch int64 = -1
+
+	unsupported int32 = iota
+	supported
+)
+
+// Extension represents the state of the acknowledgement extension
+type Extension struct {
+	supportedByServer int32
+	batch             int64
+}
+
+// New creates a new extension instance
+func New() *Extension {
+	return &Extension{supportedByServer: unsupported, batch: noBatch}
+}
+
+// Outgoing requests acknowledgements during the handshake and echoes the
+// last batch id received on /meta/connect requests
+func (e *Extension) Outgoing(ms *bayeux.Message) {
+	switch ms.Channel {
+	case bayeux.MetaHandshake:
+		ext := ms.GetExt(true)
+		ext[ExtensionName] = true
+	case bayeux.MetaConnect:
+		if e.isSupported() {
+			ext := ms.GetExt(true)
+			ext[ExtensionName] = e.LastBatch()
+		}
+	}
+}
+
+// Incoming records whether the server supports acknowledgements and the
+// batch id of each /meta/connect response
+func (e *Extension) Incoming(ms *bayeux.Message) {
+	ext := ms.GetExt(false)
+	if ext == nil {
+		return
+	}
+
+	switch ms.Channel {
+	case bayeux.MetaHandshake:
+		isSupported, ok := ext[ExtensionName].(bool)
+		if ok && isSupported {
+			atomic.StoreInt32(&e.supportedByServer, supported)
+		} else {
+			atomic.StoreInt32(&e.supportedByServer, unsupported)
+		}
+		atomic.StoreInt64(&e.batch, noBatch)
+	case bayeux.MetaConnect:
+		if batch, ok := batchID(ext[ExtensionName]); ok {
+			atomic.StoreInt64(&e.batch, batch)
+		}
+	}
+}
+
+// LastBatch returns the id of the last batch received from the server or -1
+// if none has been received yet
+func (e *Extension) LastBatch() int64 {
+	return atomic.LoadInt64(&e.batch)
+}
+
+// Registered is called after an extension has been successfully registered
+func (e *Extension) Registered(extensionName string, client *bayeux.BayeuxClient) {
+}
+
+// Unregistered is called when an extension is unregistered
+func (e *Extension) Unregistered() {
+}
+
+func (e *Extension) isSupported() bool {
+	return atomic.LoadInt32(&e.supportedByServer) == supported
+}
+
+// batchID handles the ack id whether it was decoded as a float64 or, with a
+// codec using json.Decoder.UseNumber, as a json.Number
+func batchID(v interface{}) (int64, bool) {
+	switch id := v.(type) {
+	case float64:
+		return int64(id), true
+	case json.Number:
+		n, err := id.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
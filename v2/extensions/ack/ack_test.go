@@ -0,0 +1,94 @@
+package ack
+
+import (
+	"context"
+	"testing"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+func TestOutgoingMetaHandshake(t *testing.T) {
+	e := New()
+	m := bayeux.Message{Channel: bayeux.MetaHandshake}
+	e.Outgoing(&m)
+
+	value, ok := m.Ext[ExtensionName].(bool)
+	if !ok || !value {
+		t.Fatalf("ack extension not requested in the handshake, got %v", m.Ext)
+	}
+}
+
+func TestUnsupportedOutgoingMetaConnect(t *testing.T) {
+	e := New()
+	m := bayeux.Message{Channel: bayeux.MetaConnect}
+	e.Outgoing(&m)
+
+	if m.Ext != nil {
+		t.Errorf("expected no ext on connect when unsupported, got %v", m.Ext)
+	}
+}
+
+func TestIncomingMetaConnect(t *testing.T) {
+	e := New()
+	e.Incoming(&bayeux.Message{
+		Channel: bayeux.MetaHandshake,
+		Ext:     map[string]interface{}{ExtensionName: true},
+	})
+	if got := e.LastBatch(); got != noBatch {
+		t.Errorf("expected no batch after handshake, got %d", got)
+	}
+
+	e.Incoming(&bayeux.Message{
+		Channel: bayeux.MetaConnect,
+		Ext:     map[string]interface{}{ExtensionName: float64(7)},
+	})
+	if got := e.LastBatch(); got != 7 {
+		t.Errorf("expected batch 7, got %d", got)
+	}
+
+	m := bayeux.Message{Channel: bayeux.MetaConnect}
+	e.Outgoing(&m)
+	if got, ok := m.Ext[ExtensionName].(int64); !ok || got != 7 {
+		t.Errorf("expected connect to acknowledge batch 7, got %v", m.Ext)
+	}
+}
+
+func TestAcknowledgesIncrementingBatches(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := bayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	e := New()
+	if err := client.UseExtension(e); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if !e.isSupported() {
+		t.Fatal("expected the server to accept the ack extension")
+	}
+
+	for want := int64(1); want <= 3; want++ {
+		acked := e.LastBatch()
+		if _, err := client.Connect(ctx); err != nil {
+			t.Fatalf("failed to connect (%v)", err)
+		}
+
+		sent, _ := server.LastExt(bayeux.MetaConnect)[ExtensionName].(float64)
+		if int64(sent) != acked {
+			t.Errorf("expected connect to acknowledge batch %d, got %v", acked, sent)
+		}
+		if got := e.LastBatch(); got != want {
+			t.Errorf("expected batch %d from the server, got %d", want, got)
+		}
+	}
+}
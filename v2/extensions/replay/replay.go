@@ -9,6 +9,12 @@
 //
 //	client := gobayeux.NewClient(serverAddress)
 //	client.UseExtension(replay.New(replay.NewMapStorage()))
+//
+// Salesforce's Streaming API only replays events for channels it is told a
+// replay ID for, so you will usually want to start from a known point on
+// channels you have no stored ID for:
+//
+//	client.UseExtension(replay.New(store, replay.WithInitialReplayID(replay.AllEvents)))
 package replay
 
 import (
@@ -29,11 +35,33 @@ const (
 	supported
 )
 
+const (
+	// NewEvents is the replay ID asking for only events sent after
+	// subscribing
+	NewEvents int = -1
+	// AllEvents is the replay ID asking for every event the server has
+	// retained as well as new ones
+	AllEvents int = -2
+)
+
 // Extension represents the structure of the Salesforce Bayeux
 // Message Extension and manages the state
 type Extension struct {
 	supportedByServer *int32
 	replayStore       IDStore
+	initialReplayID   *int
+}
+
+// Option configures an Extension created with New
+type Option func(*Extension)
+
+// WithInitialReplayID returns an Option which sends the given replay ID,
+// e.g., NewEvents or AllEvents, when subscribing to a channel which has no
+// replay ID in the store yet
+func WithInitialReplayID(replayID int) Option {
+	return func(e *Extension) {
+		e.initialReplayID = &replayID
+	}
 }
 
 // IDStore stores and manages the channels and replay IDs for a bayeux
@@ -46,9 +74,24 @@ type IDStore interface {
 }
 
 // New creates a new extension instance
-func New(store IDStore) *Extension {
+func New(store IDStore, opts ...Option) *Extension {
 	defaultVal := unsupported
-	return &Extension{supportedByServer: &defaultVal, replayStore: store}
+	e := &Extension{supportedByServer: &defaultVal, replayStore: store}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// ReplayID returns the last replay ID seen on the given channel so that it
+// can be persisted and used to resume later
+func (e *Extension) ReplayID(channel bayeux.Channel) (int, bool) {
+	return e.replayStore.Get(string(channel))
+}
+
+// ReplayIDs returns the last replay ID seen on every channel
+func (e *Extension) ReplayIDs() map[string]int {
+	return e.replayStore.AsMap()
 }
 
 // Outgoing attaches any additional metadata to a message
@@ -59,8 +102,17 @@ func (e *Extension) Outgoing(ms *bayeux.Message) {
 		ext[ExtensionName] = true
 	case bayeux.MetaSubscribe:
 		if e.isSupported() {
+			// The store may hand out the map it holds so work on a copy
+			stored := e.replayStore.AsMap()
+			replayIDs := make(map[string]int, len(stored)+1)
+			for channel, replayID := range stored {
+				replayIDs[channel] = replayID
+			}
+			if _, ok := replayIDs[string(ms.Subscription)]; !ok && e.initialReplayID != nil && ms.Subscription != "" {
+				replayIDs[string(ms.Subscription)] = *e.initialReplayID
+			}
 			ext := ms.GetExt(true)
-			ext[ExtensionName] = e.replayStore.AsMap()
+			ext[ExtensionName] = replayIDs
 		}
 	}
 }
@@ -104,14 +156,22 @@ func (e *Extension) Unregistered() {
 
 func (e *Extension) updateReplayID(ms *bayeux.Message) {
 	data := make(map[string]interface{})
-
-	var md *MessageData
-	if err := json.Unmarshal(ms.Data, &md); err != nil {
+	if err := json.Unmarshal(ms.Data, &data); err != nil {
 		return
 	}
 
-	if err := json.Unmarshal([]byte(md.Data), &data); err != nil {
-		return
+	// Salesforce sends the event directly in the data while other servers
+	// may wrap it as binary data
+	if _, ok := data[eventKey]; !ok {
+		var md *MessageData
+		if err := json.Unmarshal(ms.Data, &md); err != nil || md == nil {
+			return
+		}
+
+		data = make(map[string]interface{})
+		if err := json.Unmarshal([]byte(md.Data), &data); err != nil {
+			return
+		}
 	}
 	event, ok := data[eventKey]
 	if !ok {
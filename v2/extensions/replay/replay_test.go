@@ -252,3 +252,91 @@ func TestMapStorageAsMap(t *testing.T) {
 		t.Fatalf("expected m[\"/foo/bar\"] = %d, got %d", 1234, m["/foo/bar"])
 	}
 }
+
+func TestIncomingSalesforceEventUpdatesReplayID(t *testing.T) {
+	// The shape of a PushTopic event from the Salesforce Streaming API
+	data := `{
+		"event": {"createdDate": "2023-01-01T00:00:00.000Z", "replayId": 42, "type": "updated"},
+		"sobject": {"Id": "001D000000KnaXjIAJ", "Name": "Acme"}
+	}`
+
+	e := New(NewMapStorage())
+	e.Incoming(&bayeux.Message{
+		Channel: "/topic/AccountUpdates",
+		Data:    json.RawMessage(data),
+	})
+
+	got, ok := e.ReplayID("/topic/AccountUpdates")
+	if !ok || got != 42 {
+		t.Fatalf("expected replay id 42 for /topic/AccountUpdates, got %d (ok: %v)", got, ok)
+	}
+	if ids := e.ReplayIDs(); len(ids) != 1 || ids["/topic/AccountUpdates"] != 42 {
+		t.Fatalf("expected only /topic/AccountUpdates in the replay ids, got %v", ids)
+	}
+}
+
+func TestOutgoingMetaSubscribeInitialReplayID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		store   map[string]int
+		initial int
+		want    int
+	}{
+		{"new events for an unknown channel", map[string]int{}, NewEvents, NewEvents},
+		{"all events for an unknown channel", map[string]int{}, AllEvents, AllEvents},
+		{"stored id takes precedence", map[string]int{"/topic/AccountUpdates": 42}, AllEvents, 42},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			e := New(&MapStorage{store: tc.store}, WithInitialReplayID(tc.initial))
+			*e.supportedByServer = supported
+			m := bayeux.Message{Channel: bayeux.MetaSubscribe, Subscription: "/topic/AccountUpdates"}
+			e.Outgoing(&m)
+
+			value, ok := m.Ext[ExtensionName].(map[string]int)
+			if !ok {
+				t.Fatalf("replay extension value couldn't coerce to a map, got %v", m.Ext)
+			}
+			if got := value["/topic/AccountUpdates"]; got != tc.want {
+				t.Errorf("expected replay id %d, got %d", tc.want, got)
+			}
+			if _, ok := e.ReplayID("/topic/AccountUpdates"); ok != (len(tc.store) > 0) {
+				t.Error("the initial replay id should not be stored")
+			}
+		})
+	}
+}
+
+// sharedStorage is an IDStore which hands out the map it holds
+type sharedStorage struct {
+	store map[string]int
+}
+
+func (s *sharedStorage) Set(channel string, replayID int) { s.store[channel] = replayID }
+func (s *sharedStorage) Get(channel string) (int, bool) {
+	replayID, ok := s.store[channel]
+	return replayID, ok
+}
+func (s *sharedStorage) Delete(channel string) { delete(s.store, channel) }
+func (s *sharedStorage) AsMap() map[string]int { return s.store }
+
+func TestOutgoingMetaSubscribeLeavesStoreAlone(t *testing.T) {
+	store := &sharedStorage{store: map[string]int{"/topic/Other": 7}}
+	e := New(store, WithInitialReplayID(AllEvents))
+	*e.supportedByServer = supported
+	m := bayeux.Message{Channel: bayeux.MetaSubscribe, Subscription: "/topic/AccountUpdates"}
+	e.Outgoing(&m)
+
+	value, ok := m.Ext[ExtensionName].(map[string]int)
+	if !ok {
+		t.Fatalf("replay extension value couldn't coerce to a map, got %v", m.Ext)
+	}
+	if value["/topic/AccountUpdates"] != AllEvents || value["/topic/Other"] != 7 {
+		t.Errorf("expected the stored and initial replay ids, got %v", value)
+	}
+	if _, ok := store.store["/topic/AccountUpdates"]; ok {
+		t.Error("the initial replay id should not be written to the store's map")
+	}
+}
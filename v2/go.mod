@@ -4,6 +4,7 @@ go 1.18
 
 require (
 	github.com/sirupsen/logrus v1.9.3
+	go.uber.org/goleak v1.2.1
 	golang.org/x/net v0.20.0
 )
 
@@ -0,0 +1,96 @@
+package gobayeuxtest
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+// ReplayExtensionName is the key used in the ext field by ReplayExtension
+// and the Server
+const ReplayExtensionName = "replay"
+
+// ReplayExtension is a minimal replay id extension used to exercise the ext
+// field in both directions. It asks for replay ids during the handshake,
+// records the id sent with each delivered message, and sends the last ids it
+// has seen when subscribing.
+type ReplayExtension struct {
+	mu        sync.Mutex
+	supported bool
+	ids       map[gobayeux.Channel]int
+}
+
+// NewReplayExtension creates a ReplayExtension with no recorded replay ids
+func NewReplayExtension() *ReplayExtension {
+	return &ReplayExtension{ids: make(map[gobayeux.Channel]int)}
+}
+
+// Supported reports whether the server accepted the extension during the
+// handshake
+func (e *ReplayExtension) Supported() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.supported
+}
+
+// LastID returns the most recent replay id seen on the given channel
+func (e *ReplayExtension) LastID(ch gobayeux.Channel) (int, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	id, ok := e.ids[ch]
+	return id, ok
+}
+
+// Outgoing implements gobayeux.MessageExtender
+func (e *ReplayExtension) Outgoing(m *gobayeux.Message) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch m.Channel {
+	case gobayeux.MetaHandshake:
+		m.GetExt(true)[ReplayExtensionName] = true
+	case gobayeux.MetaSubscribe:
+		if id, ok := e.ids[m.Subscription]; ok {
+			m.GetExt(true)[ReplayExtensionName] = id
+		}
+	}
+}
+
+// Incoming implements gobayeux.MessageExtender
+func (e *ReplayExtension) Incoming(m *gobayeux.Message) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ext := m.GetExt(false)
+	if ext == nil {
+		return
+	}
+
+	if m.Channel == gobayeux.MetaHandshake {
+		supported, ok := ext[ReplayExtensionName].(bool)
+		e.supported = ok && supported
+		return
+	}
+
+	if m.Channel.Type() != gobayeux.BroadcastChannel {
+		return
+	}
+
+	switch id := ext[ReplayExtensionName].(type) {
+	case float64:
+		e.ids[m.Channel] = int(id)
+	case json.Number:
+		if n, err := id.Int64(); err == nil {
+			e.ids[m.Channel] = int(n)
+		}
+	}
+}
+
+// Registered implements gobayeux.MessageExtender
+func (e *ReplayExtension) Registered(string, *gobayeux.BayeuxClient) {}
+
+// Unregistered implements gobayeux.MessageExtender
+func (e *ReplayExtension) Unregistered() {}
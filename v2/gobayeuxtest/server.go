@@ -0,0 +1,483 @@
+// Package gobayeuxtest provides an in-memory Bayeux server for testing code
+// which uses gobayeux without a live CometD server. The Server is used as
+// the http.RoundTripper of a client, e.g., with gobayeux.WithHTTPTransport.
+package gobayeuxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"golang.org/x/net/context"
+)
+
+const (
+	// VERSION is the Bayeux protocol version the Server replies with
+	VERSION = "1.0"
+
+	// ACLProbeChannel answers whether the channel in the data of each
+	// message may be subscribed to
+	ACLProbeChannel gobayeux.Channel = "/service/acl"
+)
+
+var (
+	chars         = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmonpqrstuvwxyz0123456789")
+	numChars      = len(chars)
+	defaultAdvice = gobayeux.Advice{
+		Reconnect: "retry",
+		Timeout:   int((30 * time.Second).Milliseconds()),
+		Interval:  0,
+	}
+)
+
+// Logger receives the Server's log output. A *testing.T satisfies it.
+type Logger interface {
+	Log(args ...any)
+	Logf(format string, args ...any)
+}
+
+// Server is an in-memory Bayeux server which can be used as the
+// http.RoundTripper of a client.
+//
+// By default every /meta/connect delivers a generated event on each channel
+// the client is subscribed to. With SetGenerateEvents(false) only the events
+// queued with Publish are delivered and a /meta/connect with nothing to
+// deliver is held for the advised timeout, like a long-polling server.
+type Server struct {
+	log Logger
+
+	mu       sync.Mutex
+	running  bool
+	generate bool
+	clients  map[string]bool
+	queues   map[string][]*gobayeux.Message
+	wake     map[string]chan struct{}
+	subs     map[string][]gobayeux.Channel
+	denied   map[gobayeux.Channel]bool
+	advice   gobayeux.Advice
+	exts     map[gobayeux.Channel]map[string]interface{}
+	replay   map[string]bool
+	lastID   int
+	acks     map[string]bool
+	lastAck  int
+}
+
+// NewServer creates a Server which logs to logger. It does not answer
+// requests until it is started.
+func NewServer(logger Logger) *Server {
+	return &Server{
+		log:      logger,
+		generate: true,
+		clients:  make(map[string]bool),
+		queues:   make(map[string][]*gobayeux.Message),
+		wake:     make(map[string]chan struct{}),
+		subs:     make(map[string][]gobayeux.Channel),
+		denied:   make(map[gobayeux.Channel]bool),
+		advice:   defaultAdvice,
+		exts:     make(map[gobayeux.Channel]map[string]interface{}),
+		replay:   make(map[string]bool),
+		acks:     make(map[string]bool),
+	}
+}
+
+// SetAdvice changes the advice sent in response to handshake and connect
+// requests
+func (s *Server) SetAdvice(advice gobayeux.Advice) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.advice = advice
+}
+
+// SetGenerateEvents controls whether each /meta/connect delivers a generated
+// event on every subscribed channel. It is enabled by default.
+func (s *Server) SetGenerateEvents(generate bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.generate = generate
+}
+
+// Publish queues an event with the given data for every client subscribed
+// to the channel, waking any /meta/connect held waiting for events
+func (s *Server) Publish(ch gobayeux.Channel, data json.RawMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.publish(ch, data)
+}
+
+// publish queues an event for every client subscribed to the channel. s.mu
+// must be held.
+func (s *Server) publish(ch gobayeux.Channel, data json.RawMessage) {
+	for clientID, channels := range s.subs {
+		for _, subscribed := range channels {
+			if subscribed != ch {
+				continue
+			}
+
+			s.queues[clientID] = append(s.queues[clientID], &gobayeux.Message{
+				Channel: ch,
+				ID:      generateID(5),
+				Data:    data,
+			})
+			s.notify(clientID)
+		}
+	}
+}
+
+// Subscriptions returns the channels the client is subscribed to
+func (s *Server) Subscriptions(clientID string) []gobayeux.Channel {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]gobayeux.Channel(nil), s.subs[clientID]...)
+}
+
+// Deny causes subscriptions and publishes to the given channel to be rejected
+func (s *Server) Deny(ch gobayeux.Channel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.denied[ch] = true
+}
+
+// LastExt returns the ext field of the most recent message the server
+// received on the given channel
+func (s *Server) LastExt(ch gobayeux.Channel) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.exts[ch]
+}
+
+// Start makes the Server answer requests
+func (s *Server) Start(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running = true
+
+	return nil
+}
+
+// Stop makes every later request fail until the Server is started again
+func (s *Server) Stop(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running = false
+
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper by answering the Bayeux messages in
+// the request
+func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil, errors.New("server not running")
+	}
+
+	defer req.Body.Close()
+
+	var msgs []*gobayeux.Message
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, fmt.Errorf("issue reading body (%w)", err)
+	}
+
+	if err := json.Unmarshal(body, &msgs); err != nil {
+		return &http.Response{
+			StatusCode: http.StatusUnprocessableEntity,
+			Status:     http.StatusText(http.StatusUnprocessableEntity),
+		}, nil
+	}
+
+	replies := []*gobayeux.Message{}
+
+	for _, msg := range msgs {
+		if msg.Ext != nil {
+			s.exts[msg.Channel] = msg.Ext
+		}
+
+		switch msg.Channel {
+		case "/meta/handshake":
+			reply := &gobayeux.Message{
+				Channel:                  "/meta/handshake",
+				Version:                  msg.Version,
+				SupportedConnectionTypes: msg.SupportedConnectionTypes,
+				ClientID:                 generateID(10),
+				Successful:               true,
+				AuthSuccessful:           true,
+				Advice:                   s.currentAdvice(),
+				ID:                       msg.ID,
+			}
+
+			s.clients[reply.ClientID] = true
+			s.wake[reply.ClientID] = make(chan struct{}, 1)
+
+			// Clients asking for the replay extension get a replay id on
+			// every message delivered to them
+			if requested, ok := msg.Ext[ReplayExtensionName].(bool); ok && requested {
+				s.replay[reply.ClientID] = true
+				reply.GetExt(true)[ReplayExtensionName] = true
+			}
+
+			// Clients asking for acknowledgements get an incrementing batch
+			// id on every /meta/connect reply
+			if requested, ok := msg.Ext["ack"].(bool); ok && requested {
+				s.acks[reply.ClientID] = true
+				reply.GetExt(true)["ack"] = true
+			}
+
+			replies = append(replies, reply)
+		case "/meta/connect":
+			if !s.clients[msg.ClientID] {
+				replies = append(replies, &gobayeux.Message{
+					Channel:  "/meta/connect",
+					ClientID: msg.ClientID,
+					ID:       msg.ID,
+					Error:    "402::Unknown client",
+					Advice:   &gobayeux.Advice{Reconnect: "handshake"},
+				})
+				continue
+			}
+
+			deliveries := s.deliveries(req.Context(), msg.ClientID)
+			for _, delivery := range deliveries {
+				delivery.ClientID = msg.ClientID
+				delivery.Successful = true
+				if s.replay[msg.ClientID] {
+					s.lastID++
+					delivery.Ext = map[string]interface{}{ReplayExtensionName: s.lastID}
+				}
+
+				replies = append(replies, delivery)
+			}
+
+			reply := &gobayeux.Message{
+				Channel:    "/meta/connect",
+				Successful: true,
+				ClientID:   msg.ClientID,
+				Advice:     s.currentAdvice(),
+				ID:         msg.ID,
+			}
+
+			if s.acks[msg.ClientID] {
+				s.lastAck++
+				reply.GetExt(true)["ack"] = s.lastAck
+			}
+
+			replies = append(replies, reply)
+		case "/meta/subscribe":
+			if _, ok := s.subs[msg.ClientID]; !ok {
+				s.subs[msg.ClientID] = make([]gobayeux.Channel, 0)
+			}
+
+			reply := &gobayeux.Message{
+				Channel:      "/meta/subscribe",
+				ID:           msg.ID,
+				ClientID:     msg.ClientID,
+				Successful:   true,
+				Subscription: msg.Subscription,
+			}
+
+			if s.denied[msg.Subscription] {
+				reply.Successful = false
+				reply.Error = fmt.Sprintf("403:%s,%s:denied", msg.ClientID, msg.Subscription)
+				replies = append(replies, reply)
+				continue
+			}
+
+			// Subscribing is idempotent so a repeated subscription simply
+			// succeeds again
+			subscribed := false
+			for _, ch := range s.subs[msg.ClientID] {
+				if ch == msg.Subscription {
+					subscribed = true
+				}
+			}
+
+			if !subscribed {
+				s.subs[msg.ClientID] = append(s.subs[msg.ClientID], msg.Subscription)
+			}
+
+			replies = append(replies, reply)
+		case "/meta/unsubscribe":
+			if _, ok := s.subs[msg.ClientID]; !ok {
+				s.subs[msg.ClientID] = make([]gobayeux.Channel, 0)
+			}
+
+			reply := &gobayeux.Message{
+				Channel:      "/meta/unsubscribe",
+				ID:           msg.ID,
+				ClientID:     msg.ClientID,
+				Successful:   true,
+				Subscription: msg.Subscription,
+			}
+
+			found := false
+			subs := []gobayeux.Channel{}
+			for _, ch := range s.subs[msg.ClientID] {
+				if ch == msg.Subscription {
+					found = true
+					continue
+				}
+
+				subs = append(subs, ch)
+			}
+
+			s.subs[msg.ClientID] = subs
+
+			if !found {
+				reply.Successful = false
+				reply.Error = fmt.Sprintf("403:%s,%s:not subscribed", msg.ClientID, msg.Subscription)
+			}
+
+			replies = append(replies, reply)
+		case ACLProbeChannel:
+			var probe struct {
+				Channel gobayeux.Channel `json:"channel"`
+			}
+			if err := json.Unmarshal(msg.Data, &probe); err != nil {
+				return nil, fmt.Errorf("issue reading acl probe (%w)", err)
+			}
+
+			reply := &gobayeux.Message{
+				Channel:    ACLProbeChannel,
+				ClientID:   msg.ClientID,
+				Data:       msg.Data,
+				Successful: true,
+			}
+
+			if s.denied[probe.Channel] {
+				reply.Successful = false
+				reply.Error = fmt.Sprintf("403:%s,%s:denied", msg.ClientID, probe.Channel)
+			}
+
+			replies = append(replies, reply)
+		case "/meta/disconnect":
+			s.notify(msg.ClientID)
+			delete(s.clients, msg.ClientID)
+			delete(s.queues, msg.ClientID)
+			delete(s.wake, msg.ClientID)
+			delete(s.subs, msg.ClientID)
+			delete(s.replay, msg.ClientID)
+			delete(s.acks, msg.ClientID)
+
+			replies = append(replies, &gobayeux.Message{
+				Channel:    "/meta/disconnect",
+				ID:         msg.ID,
+				ClientID:   msg.ClientID,
+				Successful: true,
+			})
+		default:
+			if msg.Channel.Type() == gobayeux.MetaChannel {
+				s.log.Logf("unhandled: %+v", msg)
+				continue
+			}
+
+			// Anything else is a client publishing
+			reply := &gobayeux.Message{
+				Channel:    msg.Channel,
+				ID:         msg.ID,
+				Successful: true,
+			}
+			switch {
+			case !s.clients[msg.ClientID]:
+				reply.Successful = false
+				reply.Error = "402::Unknown client"
+				reply.Advice = &gobayeux.Advice{Reconnect: "handshake"}
+			case s.denied[msg.Channel]:
+				reply.Successful = false
+				reply.Error = fmt.Sprintf("403:%s,%s:denied", msg.ClientID, msg.Channel)
+			case msg.Channel.Type() == gobayeux.BroadcastChannel:
+				s.publish(msg.Channel, msg.Data)
+			}
+
+			replies = append(replies, reply)
+		}
+	}
+
+	reply, err := json.Marshal(replies)
+	if err != nil {
+		return nil, fmt.Errorf("issue marshaling body (%w)", err)
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader(reply)),
+	}, nil
+}
+
+// deliveries returns the events for a /meta/connect from the client. Without
+// generated events it waits, with s.mu unlocked, for an event to be
+// published until the advised timeout passes or the request is cancelled.
+func (s *Server) deliveries(ctx context.Context, clientID string) []*gobayeux.Message {
+	if s.generate {
+		var generated []*gobayeux.Message
+		for _, ch := range s.subs[clientID] {
+			generated = append(generated, &gobayeux.Message{
+				Channel: ch,
+				ID:      generateID(5),
+				Data:    json.RawMessage(`{}`),
+			})
+		}
+		return generated
+	}
+
+	// Publishing while no connect was held leaves a stale wake up behind
+	wake := s.wake[clientID]
+	select {
+	case <-wake:
+	default:
+	}
+
+	if len(s.queues[clientID]) == 0 {
+		timeout := s.advice.TimeoutAsDuration()
+		s.mu.Unlock()
+		select {
+		case <-wake:
+		case <-time.After(timeout):
+		case <-ctx.Done():
+		}
+		s.mu.Lock()
+	}
+
+	queued := s.queues[clientID]
+	delete(s.queues, clientID)
+	return queued
+}
+
+// notify wakes a /meta/connect from the client held waiting for events
+func (s *Server) notify(clientID string) {
+	select {
+	case s.wake[clientID] <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Server) currentAdvice() *gobayeux.Advice {
+	advice := s.advice
+	return &advice
+}
+
+func generateID(length int) string {
+	ret := make([]rune, length)
+	for i := range ret {
+		ret[i] = chars[rand.Intn(numChars)]
+	}
+
+	return string(ret)
+}
@@ -0,0 +1,118 @@
+package gobayeuxtest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestServerPublish(t *testing.T) {
+	server := NewServer(t)
+	server.SetGenerateEvents(false)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx := context.Background()
+	ms, err := client.Handshake(ctx)
+	if err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	clientID := ms[0].ClientID
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar", "/foo/baz"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	if got, want := server.Subscriptions(clientID), []gobayeux.Channel{"/foo/bar", "/foo/baz"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected subscriptions %v, got %v", want, got)
+	}
+
+	server.Publish("/foo/bar", json.RawMessage(`{"n":1}`))
+	server.Publish("/foo/other", json.RawMessage(`{"n":2}`))
+	server.Publish("/foo/baz", json.RawMessage(`{"n":3}`))
+	ms, err = client.Connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+	var data []string
+	for _, m := range ms {
+		if m.Channel != gobayeux.MetaConnect {
+			data = append(data, string(m.Channel)+" "+string(m.Data))
+		}
+	}
+	if want := []string{`/foo/bar {"n":1}`, `/foo/baz {"n":3}`}; !reflect.DeepEqual(data, want) {
+		t.Errorf("expected the published events %v, got %v", want, data)
+	}
+
+	// With nothing queued the connect is held until an event is published
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		server.Publish("/foo/bar", json.RawMessage(`{"n":4}`))
+	}()
+	start := time.Now()
+	ms, err = client.Connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+	if len(ms) != 2 || string(ms[0].Data) != `{"n":4}` {
+		t.Errorf("expected the event published while waiting, got %+v", ms)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("expected the connect to be held until the event, returned after %s", waited)
+	}
+
+	// Without events the connect is held for the advised timeout
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 10})
+	if ms, err = client.Connect(ctx); err != nil || len(ms) != 1 {
+		t.Errorf("expected only the /meta/connect reply, got %+v (%v)", ms, err)
+	}
+
+	if _, err := client.Unsubscribe(ctx, []gobayeux.Channel{"/foo/baz"}); err != nil {
+		t.Fatalf("failed to unsubscribe (%v)", err)
+	}
+	if got, want := server.Subscriptions(clientID), []gobayeux.Channel{"/foo/bar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected subscriptions %v, got %v", want, got)
+	}
+
+	if _, err := client.Disconnect(ctx); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+	if got := server.Subscriptions(clientID); len(got) != 0 {
+		t.Errorf("expected no subscriptions after disconnecting, got %v", got)
+	}
+}
+
+func TestServerUnknownClient(t *testing.T) {
+	server := NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	body := `[{"channel":"/meta/connect","clientId":"unknown","connectionType":"long-polling"}]`
+	req, err := http.NewRequest(http.MethodPost, "https://example.com", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request (%v)", err)
+	}
+	resp, err := server.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+	defer resp.Body.Close()
+
+	var ms []gobayeux.Message
+	if err := json.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode response (%v)", err)
+	}
+	if len(ms) != 1 || ms[0].Successful || !ms[0].Advice.ShouldHandshake() {
+		t.Errorf("expected an unsuccessful reply advising a handshake, got %+v", ms)
+	}
+}
@@ -0,0 +1,154 @@
+package gobayeux
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// handoverPollInterval is how often Handover checks whether the new Client
+// is receiving
+const handoverPollInterval = 10 * time.Millisecond
+
+// HandoverOption configures Handover
+type HandoverOption func(*handoverOptions)
+
+type handoverOptions struct {
+	dedupeWindow int
+}
+
+// WithHandoverDedupe returns a HandoverOption which delivers each message
+// only once while both Clients are connected. The IDs of the last window
+// messages delivered on each channel are remembered and a message with one
+// of those IDs is dropped.
+func WithHandoverDedupe(window int) HandoverOption {
+	return func(options *handoverOptions) {
+		options.dedupeWindow = window
+	}
+}
+
+// Handover moves the subscriptions to channels from old to next without a
+// gap in delivery, e.g., during a zero-downtime deploy. next must already
+// have been started.
+//
+// Handover subscribes next to each channel with the receivers old delivers
+// to, waits until the server has accepted every subscription and next has
+// completed a /meta/connect, and only then disconnects old. Messages
+// arriving while both Clients are connected are delivered by each of them
+// unless WithHandoverDedupe is given.
+//
+// If ctx is done before next is receiving, old is left connected and ctx's
+// error is returned.
+func Handover(ctx context.Context, old, next *Client, channels []Channel, opts ...HandoverOption) error {
+	var options handoverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	receivers := make(map[Channel][]chan []Message, len(channels))
+	for _, channel := range channels {
+		rs, err := old.subscriptions.Get(channel)
+		if err != nil {
+			return fmt.Errorf("handover of %s: %w", channel, err)
+		}
+		receivers[channel] = rs
+	}
+
+	var dedupe *dedupeFilter
+	if options.dedupeWindow > 0 {
+		dedupe = newDedupeFilter(options.dedupeWindow)
+	}
+
+	for _, channel := range channels {
+		rs := receivers[channel]
+		if dedupe != nil {
+			// Both Clients deliver through the same filter for the overlap
+			fromOld := make(chan []Message, cap(rs[0]))
+			old.goroutines.Add(1)
+			go forward(&old.goroutines, old.shutdown, fromOld, rs, dedupe)
+			old.subscriptions.Replace(channel, fromOld)
+		}
+		if dedupe == nil && len(rs) == 1 {
+			if err := next.Subscribe(channel, rs[0]); err != nil {
+				return err
+			}
+			continue
+		}
+		fromNext := make(chan []Message, cap(rs[0]))
+		next.goroutines.Add(1)
+		go forward(&next.goroutines, next.shutdown, fromNext, rs, dedupe)
+		if err := next.Subscribe(channel, fromNext); err != nil {
+			return err
+		}
+	}
+
+	if err := next.waitUntilReceiving(ctx, channels); err != nil {
+		return err
+	}
+	return old.Disconnect(ctx)
+}
+
+// waitUntilReceiving waits until every channel is subscribed and a
+// /meta/connect started after that has succeeded
+func (c *Client) waitUntilReceiving(ctx context.Context, channels []Channel) error {
+	ticker := time.NewTicker(handoverPollInterval)
+	defer ticker.Stop()
+
+	subscribed := false
+	var connects uint64
+	for {
+		if !subscribed {
+			subscribed = true
+			for _, channel := range channels {
+				if _, err := c.subscriptions.Get(channel); err != nil {
+					subscribed = false
+					break
+				}
+			}
+			if subscribed {
+				connects = c.successfulConnects()
+			}
+		} else if c.successfulConnects() > connects {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Client) successfulConnects() uint64 {
+	stats := c.stats.snapshot()
+	return stats.Latency[OperationConnect].Count - stats.Errors[OperationConnect]
+}
+
+// forward delivers each batch from ms to every receiver until done is
+// closed, leaving out the messages dedupe has already seen
+func forward(wg *sync.WaitGroup, done <-chan struct{}, ms chan []Message, receivers []chan []Message, dedupe *dedupeFilter) {
+	defer wg.Done()
+	for {
+		select {
+		case <-done:
+			return
+		case batch := <-ms:
+			if dedupe != nil {
+				batch = dedupe.filter(batch)
+				if len(batch) == 0 {
+					continue
+				}
+			}
+			copies := copiesFor(batch, len(receivers))
+			for i, receiver := range receivers {
+				select {
+				case receiver <- copies[i]:
+				case <-done:
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+// sequencedServer publishes one event with the next sequential ID every time
+// any client connects and delivers each subscribed client every event since
+// it subscribed, so two clients connected at once see the same events
+type sequencedServer struct {
+	mu      sync.Mutex
+	events  int
+	clients map[string]*sequencedClient
+}
+
+type sequencedClient struct {
+	subscribed   bool
+	cursor       int
+	disconnected bool
+}
+
+func newSequencedServer() *sequencedServer {
+	return &sequencedServer{clients: make(map[string]*sequencedClient)}
+}
+
+func (s *sequencedServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	var requests []gobayeux.Message
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		return nil, err
+	}
+
+	var replies []gobayeux.Message
+	for _, m := range requests {
+		if m.Channel == gobayeux.MetaConnect {
+			// Hold connects briefly like a server waiting for events
+			time.Sleep(2 * time.Millisecond)
+		}
+
+		s.mu.Lock()
+		reply := gobayeux.Message{Channel: m.Channel, ID: m.ID, ClientID: m.ClientID, Successful: true}
+		switch m.Channel {
+		case gobayeux.MetaHandshake:
+			reply.ClientID = fmt.Sprintf("client-%d", len(s.clients)+1)
+			reply.SupportedConnectionTypes = []string{gobayeux.ConnectionTypeLongPolling}
+			s.clients[reply.ClientID] = &sequencedClient{}
+		case gobayeux.MetaSubscribe:
+			reply.Subscription = m.Subscription
+			client := s.clients[m.ClientID]
+			client.subscribed = true
+			client.cursor = s.events
+		case gobayeux.MetaConnect:
+			s.events++
+			if client := s.clients[m.ClientID]; client.subscribed {
+				for ; client.cursor < s.events; client.cursor++ {
+					replies = append(replies, gobayeux.Message{
+						Channel: "/foo/bar",
+						ID:      strconv.Itoa(client.cursor + 1),
+						Data:    json.RawMessage(`{}`),
+					})
+				}
+			}
+		case gobayeux.MetaDisconnect:
+			s.clients[m.ClientID].disconnected = true
+		}
+		s.mu.Unlock()
+		replies = append(replies, reply)
+	}
+
+	body, err := json.Marshal(replies)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+func (s *sequencedServer) disconnected(clientID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.clients[clientID].disconnected
+}
+
+func TestHandover(t *testing.T) {
+	testCases := []struct {
+		name   string
+		opts   []gobayeux.HandoverOption
+		dedupe bool
+	}{
+		{"overlapping", nil, false},
+		{"deduplicated", []gobayeux.HandoverOption{gobayeux.WithHandoverDedupe(100)}, true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			server := newSequencedServer()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			clientErrs := make(chan error, 10)
+			newClient := func() *gobayeux.Client {
+				client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+				if err != nil {
+					t.Fatalf("failed to create client (%v)", err)
+				}
+				errs := client.Start(ctx)
+				go func() {
+					for err := range errs {
+						clientErrs <- err
+					}
+				}()
+				return client
+			}
+
+			var mu sync.Mutex
+			delivered := make(map[int]int)
+			msgs := make(chan []gobayeux.Message, 100)
+			received := make(chan struct{})
+			go func() {
+				var once sync.Once
+				for {
+					select {
+					case ms := <-msgs:
+						mu.Lock()
+						for _, m := range ms {
+							id, _ := strconv.Atoi(m.ID)
+							delivered[id]++
+						}
+						mu.Unlock()
+						once.Do(func() { close(received) })
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+
+			old := newClient()
+			old.Subscribe("/foo/bar", msgs)
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the old client to receive")
+			}
+
+			next := newClient()
+			handoverCtx, handoverCancel := context.WithTimeout(ctx, 5*time.Second)
+			defer handoverCancel()
+			if err := gobayeux.Handover(handoverCtx, old, next, []gobayeux.Channel{"/foo/bar"}, tc.opts...); err != nil {
+				t.Fatalf("failed to hand over (%v)", err)
+			}
+			if !server.disconnected(old.SessionInfo().ClientID) {
+				t.Error("expected the old client to be disconnected")
+			}
+
+			// Let the new client deliver on its own for a while
+			time.Sleep(50 * time.Millisecond)
+			if err := next.Disconnect(ctx); err != nil {
+				t.Fatalf("failed to disconnect (%v)", err)
+			}
+
+			select {
+			case err := <-clientErrs:
+				t.Fatalf("unexpected error from client (%v)", err)
+			default:
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			first, last, duplicates := -1, 0, 0
+			for id, count := range delivered {
+				if first == -1 || id < first {
+					first = id
+				}
+				if id > last {
+					last = id
+				}
+				duplicates += count - 1
+			}
+			for id := first; id <= last; id++ {
+				if delivered[id] == 0 {
+					t.Errorf("expected event %d between %d and %d to be delivered", id, first, last)
+				}
+			}
+			if tc.dedupe && duplicates > 0 {
+				t.Errorf("expected no duplicates, got %d of %d events", duplicates, len(delivered))
+			}
+		})
+	}
+}
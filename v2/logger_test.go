@@ -0,0 +1,232 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+// recordingLogger keeps every entry logged through it or the Loggers derived
+// from it
+type recordingLogger struct {
+	entries *[]logEntry
+	lock    *sync.Mutex
+	fields  map[string]any
+}
+
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]any
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{entries: new([]logEntry), lock: new(sync.Mutex), fields: map[string]any{}}
+}
+
+func (l *recordingLogger) log(level, msg string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	*l.entries = append(*l.entries, logEntry{level, msg, l.fields})
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.log("debug", msg) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.log("info", msg) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.log("warn", msg) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.log("error", msg) }
+
+func (l *recordingLogger) WithError(err error) gobayeux.Logger {
+	return l.WithField("error", err)
+}
+
+func (l *recordingLogger) WithField(key string, value any) gobayeux.Logger {
+	fields := make(map[string]any, len(l.fields)+1)
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	fields[key] = value
+	return &recordingLogger{entries: l.entries, lock: l.lock, fields: fields}
+}
+
+// find returns the entries with the message and fields given
+func (l *recordingLogger) find(msg string, fields map[string]any) []logEntry {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var found []logEntry
+_entries:
+	for _, entry := range *l.entries {
+		if entry.msg != msg {
+			continue
+		}
+		for k, v := range fields {
+			if entry.fields[k] != v {
+				continue _entries
+			}
+		}
+		found = append(found, entry)
+	}
+	return found
+}
+
+func TestMessageLogFields(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	logger := newRecordingLogger()
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", logger)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	clientID := client.SessionInfo().ClientID
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	ms, err := client.Connect(ctx)
+	if err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+
+	for _, m := range ms {
+		want := map[string]any{
+			"at":         gobayeux.OperationConnect,
+			"channel":    m.Channel,
+			"id":         m.ID,
+			"clientID":   clientID,
+			"successful": m.Successful,
+		}
+		if entries := logger.find("received message", want); len(entries) != 1 {
+			t.Errorf("expected one entry with fields %v, got %d", want, len(entries))
+		}
+	}
+
+	for _, op := range []string{gobayeux.OperationSubscribe, gobayeux.OperationConnect} {
+		entries := logger.find("finishing", map[string]any{"at": op, "clientID": clientID})
+		if len(entries) != 1 || entries[0].level != "debug" {
+			t.Errorf("expected %s to log the clientID at debug level, got %v", op, entries)
+		}
+	}
+}
+
+// stdLogger adapts a *log.Logger from the standard library, writing the
+// fields after the message in the order they were added
+type stdLogger struct {
+	logger *log.Logger
+	fields string
+}
+
+func (l *stdLogger) print(level, msg string) {
+	l.logger.Printf("%s %s%s", level, msg, l.fields)
+}
+
+func (l *stdLogger) Debug(msg string, args ...any) { l.print("DEBUG", msg) }
+func (l *stdLogger) Info(msg string, args ...any)  { l.print("INFO", msg) }
+func (l *stdLogger) Warn(msg string, args ...any)  { l.print("WARN", msg) }
+func (l *stdLogger) Error(msg string, args ...any) { l.print("ERROR", msg) }
+
+func (l *stdLogger) WithError(err error) gobayeux.Logger {
+	return l.WithField("error", err)
+}
+
+func (l *stdLogger) WithField(key string, value any) gobayeux.Logger {
+	return &stdLogger{logger: l.logger, fields: fmt.Sprintf("%s %s=%q", l.fields, key, fmt.Sprint(value))}
+}
+
+func ExampleLogger() {
+	logger := &stdLogger{logger: log.New(os.Stdout, "", 0)}
+
+	handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Status:     http.StatusText(http.StatusInternalServerError),
+		}, nil
+	})
+
+	client, err := gobayeux.NewClient("http://127.0.0.1:9876",
+		gobayeux.WithLogger(logger),
+		gobayeux.WithHTTPTransport(handler),
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	errs := client.Start(context.Background())
+	if err := <-errs; err == nil {
+		panic("expected an error when connecting")
+	}
+	// Output:
+	// DEBUG starting at="handshake"
+	// DEBUG error parsing response at="handshake" error="handshake failed: 500 Internal Server Error"
+}
+
+func TestWithPayloadLogging(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	var sent []byte
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		sent = body
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		return server.RoundTrip(r)
+	})
+
+	redact := func(ms []gobayeux.Message) []gobayeux.Message {
+		for i := range ms {
+			if _, ok := ms[i].Ext["token"]; ok {
+				ms[i].Ext["token"] = "[redacted]"
+			}
+		}
+		return ms
+	}
+	logger := newRecordingLogger()
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", logger,
+		gobayeux.WithHandshakeExt(map[string]interface{}{"token": "secret"}),
+		gobayeux.WithPayloadLogging(redact),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if _, err := client.Handshake(context.Background()); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	if !bytes.Contains(sent, []byte(`"secret"`)) {
+		t.Errorf("expected the redactor to leave the request alone, sent %s", sent)
+	}
+	entries := logger.find("sending payload", map[string]any{"at": "request"})
+	if len(entries) != 1 {
+		t.Fatalf("expected the request payload to be logged once, got %d entries", len(entries))
+	}
+	payload, _ := entries[0].fields["payload"].(string)
+	if strings.Contains(payload, "secret") || !strings.Contains(payload, "[redacted]") {
+		t.Errorf("expected the token to be redacted, logged %s", payload)
+	}
+	if entries[0].level != "debug" {
+		t.Errorf("expected payloads to be logged at debug level, got %s", entries[0].level)
+	}
+	if entries := logger.find("received payload", map[string]any{"at": gobayeux.OperationHandshake}); len(entries) != 1 {
+		t.Errorf("expected the response payload to be logged once, got %d entries", len(entries))
+	}
+}
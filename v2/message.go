@@ -99,6 +99,10 @@ type Message struct {
 	//
 	// See also: https://docs.cometd.org/current/reference/#_bayeux_ext
 	Ext map[string]interface{} `json:"ext,omitempty"`
+	// Raw holds the message exactly as it was received from the server,
+	// including any fields not modeled by this struct. It is only populated
+	// for JSON responses and is never sent to the server.
+	Raw json.RawMessage `json:"-"`
 }
 
 // TimestampAsTime returns the Timestamp in a message as a time.Time struct
@@ -137,6 +141,23 @@ func (m *Message) GetExt(create bool) map[string]interface{} {
 	return m.Ext
 }
 
+// Reconnect advice values
+//
+// See also: https://docs.cometd.org/current/reference/#_reconnect_advice_field
+const (
+	// ReconnectRetry advises the client to retry the /meta/connect after
+	// the interval
+	ReconnectRetry = "retry"
+	// ReconnectHandshake advises the client to handshake again
+	ReconnectHandshake = "handshake"
+	// ReconnectNone advises the client to neither retry nor handshake
+	ReconnectNone = "none"
+)
+
+// DefaultMaxNetworkDelay is the maxNetworkDelay a client assumes, in
+// milliseconds, until the server advises otherwise
+const DefaultMaxNetworkDelay = 10000
+
 // Advice represents the field from the server which is used to inform clients
 // of their preferred mode of client operation.
 //
@@ -157,6 +178,11 @@ type Advice struct {
 	//
 	// See also: https://docs.cometd.org/current/reference/#_interval_advice_field
 	Interval int `json:"interval,omitempty"`
+	// MaxNetworkDelay is the period of time, in milliseconds, the client
+	// should allow on top of Timeout for a /meta/connect to be answered.
+	//
+	// See also: https://docs.cometd.org/current/reference/#_bayeux_advice
+	MaxNetworkDelay int `json:"maxNetworkDelay,omitempty"`
 	// MultipleClients indicates that the server has detected multiple Bayeux
 	// client instances running within the same web client
 	//
@@ -170,37 +196,117 @@ type Advice struct {
 	//
 	// See also: https://docs.cometd.org/current/reference/#_hosts_advice_field
 	Hosts []string `json:"hosts,omitempty"`
+
+	// intervalSet records that the server sent an interval, since an
+	// interval of 0 is advice in its own right
+	intervalSet bool
+}
+
+// DefaultAdvice returns the advice in effect before the server gives any:
+// retry straight away allowing DefaultMaxNetworkDelay for each response
+func DefaultAdvice() *Advice {
+	return &Advice{Reconnect: ReconnectRetry, MaxNetworkDelay: DefaultMaxNetworkDelay}
+}
+
+// UnmarshalJSON implements json.Unmarshaler recording which fields the
+// server sent
+func (a *Advice) UnmarshalJSON(data []byte) error {
+	type advice Advice
+	var fields struct {
+		advice
+		Interval *int `json:"interval"`
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*a = Advice(fields.advice)
+	if fields.Interval != nil {
+		a.Interval = *fields.Interval
+		a.intervalSet = true
+	}
+	return nil
+}
+
+// Merge returns the advice to follow once newer is received after a. Fields
+// newer leaves out keep their value from a and fields neither sets take
+// their value from DefaultAdvice. MultipleClients only describes the
+// response it was sent in so it is never inherited. Neither a nor newer is
+// modified.
+func (a *Advice) Merge(newer *Advice) *Advice {
+	merged := DefaultAdvice()
+	merged.override(a)
+	merged.override(newer)
+	return merged
+}
+
+// override replaces the fields of a which newer sets
+func (a *Advice) override(newer *Advice) {
+	if newer == nil {
+		return
+	}
+	if newer.Reconnect != "" {
+		a.Reconnect = newer.Reconnect
+	}
+	if newer.Timeout > 0 {
+		a.Timeout = newer.Timeout
+	}
+	if newer.intervalSet || newer.Interval > 0 {
+		a.Interval = newer.Interval
+		a.intervalSet = true
+	}
+	if newer.MaxNetworkDelay > 0 {
+		a.MaxNetworkDelay = newer.MaxNetworkDelay
+	}
+	if len(newer.Hosts) > 0 {
+		a.Hosts = append([]string(nil), newer.Hosts...)
+	}
+	a.MultipleClients = newer.MultipleClients
 }
 
 // MustNotRetryOrHandshake indicates whether neither a handshake or retry is
 // allowed
-func (a Advice) MustNotRetryOrHandshake() bool {
-	return a.Reconnect == "none"
+func (a *Advice) MustNotRetryOrHandshake() bool {
+	return a != nil && a.Reconnect == ReconnectNone
 }
 
 // ShouldRetry indicates whether a retry should occur
-func (a Advice) ShouldRetry() bool {
-	return a.Reconnect == "retry"
+func (a *Advice) ShouldRetry() bool {
+	return a != nil && a.Reconnect == ReconnectRetry
 }
 
 // ShouldHandshake indicates whether the advice is that a handshake should
 // occur
-func (a Advice) ShouldHandshake() bool {
-	return a.Reconnect == "handshake"
+func (a *Advice) ShouldHandshake() bool {
+	return a != nil && a.Reconnect == ReconnectHandshake
 }
 
 // TimeoutAsDuration returns the Timeout field as a time.Duration for
 // scheduling
-func (a Advice) TimeoutAsDuration() time.Duration {
+func (a *Advice) TimeoutAsDuration() time.Duration {
+	if a == nil {
+		return 0
+	}
 	return time.Duration(a.Timeout) * time.Millisecond
 }
 
 // IntervalAsDuration returns the Timeout field as a time.Duration for
 // scheduling
-func (a Advice) IntervalAsDuration() time.Duration {
+func (a *Advice) IntervalAsDuration() time.Duration {
+	if a == nil {
+		return 0
+	}
 	return time.Duration(a.Interval) * time.Millisecond
 }
 
+// MaxNetworkDelayAsDuration returns the MaxNetworkDelay field as a
+// time.Duration
+func (a *Advice) MaxNetworkDelayAsDuration() time.Duration {
+	if a == nil {
+		return 0
+	}
+	return time.Duration(a.MaxNetworkDelay) * time.Millisecond
+}
+
 // MessageError represents a parsed Error field of a Message
 //
 // See also: https://docs.cometd.org/current/reference/#_error
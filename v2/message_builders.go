@@ -15,6 +15,7 @@ type HandshakeRequestBuilder struct {
 	supportedConnectionTypes []string
 	// Optional fields
 	minimumVersion string
+	ext            map[string]interface{}
 }
 
 // NewHandshakeRequestBuilder provides an easy way to build a Message that can
@@ -68,6 +69,15 @@ func (b *HandshakeRequestBuilder) AddMinimumVersion(version string) error {
 	return nil
 }
 
+// AddExt sets key in the ext field of the handshake request, e.g., for
+// authentication data the server expects when the session is created
+func (b *HandshakeRequestBuilder) AddExt(key string, value interface{}) {
+	if b.ext == nil {
+		b.ext = make(map[string]interface{})
+	}
+	b.ext[key] = value
+}
+
 // Build generates the final Message to be sent as a Handshake Request
 func (b *HandshakeRequestBuilder) Build() ([]Message, error) {
 	if len(b.supportedConnectionTypes) < 1 {
@@ -84,8 +94,10 @@ func (b *HandshakeRequestBuilder) Build() ([]Message, error) {
 	if len(b.minimumVersion) > 0 {
 		m.MinimumVersion = b.minimumVersion
 	}
-	// TODO After we've added methods for id, ext, and minimumVersion, update
-	// those values in the struct here as well
+	// Extensions add to the ext after Build so each Message gets its own map
+	for key, value := range b.ext {
+		m.GetExt(true)[key] = value
+	}
 	return []Message{m}, nil
 }
 
@@ -122,8 +134,6 @@ func (b *ConnectRequestBuilder) AddConnectionType(connectionType string) error {
 	return nil
 }
 
-// TODO Add methods for id and ext
-
 // Build generates the final Message to be sent as a Connect Request
 func (b *ConnectRequestBuilder) Build() ([]Message, error) {
 	if b.clientID == "" {
@@ -139,8 +149,6 @@ func (b *ConnectRequestBuilder) Build() ([]Message, error) {
 		ClientID:       b.clientID,
 		ConnectionType: b.connectionType,
 	}
-	// TODO After we've added methods for id and ext, update
-	// those values in the struct here as well
 	return []Message{m}, nil
 }
 
@@ -199,9 +207,6 @@ func (b *SubscribeRequestBuilder) Build() ([]Message, error) {
 			Subscription: b.subscription[i],
 		}
 	}
-
-	// TODO Add the ext and id fields once we're able to handle them with the
-	// builder
 	return ms, nil
 }
 
@@ -260,8 +265,6 @@ func (b *UnsubscribeRequestBuilder) Build() ([]Message, error) {
 			Subscription: b.subscription[i],
 		}
 	}
-	// TODO Add the ext and id fields once we're able to handle them with the
-	// builder
 	return ms, nil
 }
 
@@ -45,6 +45,55 @@ func TestHandshakeRequestBuilder_AddSupportedConnectionType(t *testing.T) {
 	}
 }
 
+func TestHandshakeRequestBuilder_AddExt(t *testing.T) {
+	b := NewHandshakeRequestBuilder()
+	if err := b.AddVersion("1.0"); err != nil {
+		t.Fatalf("unable to add version for test: %q", err)
+	}
+	if err := b.AddSupportedConnectionType(ConnectionTypeLongPolling); err != nil {
+		t.Fatalf("unable to add connection type for test: %q", err)
+	}
+	b.AddExt("token", "abc")
+	b.AddExt("session", 42)
+
+	first, err := b.Build()
+	if err != nil {
+		t.Fatalf("expected handshake to build but got err %q", err)
+	}
+	if ext := first[0].Ext; len(ext) != 2 || ext["token"] != "abc" || ext["session"] != 42 {
+		t.Errorf("expected the ext to hold token and session, got %v", ext)
+	}
+
+	second, _ := b.Build()
+	first[0].GetExt(true)["extension"] = true
+	if _, ok := second[0].Ext["extension"]; ok {
+		t.Error("expected each built Message to have its own ext")
+	}
+}
+
+func TestHandshakeRequestBuilder_AddMinimumVersion(t *testing.T) {
+	b := NewHandshakeRequestBuilder()
+	if err := b.AddMinimumVersion("a.0"); err == nil {
+		t.Error("expected an invalid minimum version to be rejected")
+	}
+	for _, add := range []func(string) error{b.AddVersion, b.AddMinimumVersion} {
+		if err := add("1.0"); err != nil {
+			t.Fatalf("expected version 1.0 to be valid but got err %q", err)
+		}
+	}
+	if err := b.AddSupportedConnectionType(ConnectionTypeLongPolling); err != nil {
+		t.Fatalf("unable to add connection type for test: %q", err)
+	}
+
+	ms, err := b.Build()
+	if err != nil {
+		t.Fatalf("expected handshake to build but got err %q", err)
+	}
+	if ms[0].Version != "1.0" || ms[0].MinimumVersion != "1.0" {
+		t.Errorf("expected version and minimumVersion 1.0, got %q and %q", ms[0].Version, ms[0].MinimumVersion)
+	}
+}
+
 func TestHandshakeRequestBuilder_AddVersion(t *testing.T) {
 	testCases := []struct {
 		name      string
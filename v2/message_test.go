@@ -1,6 +1,8 @@
 package gobayeux
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -318,3 +320,159 @@ func TestAdvice_IntervalAsDuration(t *testing.T) {
 		})
 	}
 }
+
+func TestAdvice_NilAndZeroValue(t *testing.T) {
+	testCases := []struct {
+		name   string
+		advice *Advice
+	}{
+		{"nil advice", nil},
+		{"zero-value advice", &Advice{}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			a := tc.advice
+			if a.MustNotRetryOrHandshake() {
+				t.Error("expected MustNotRetryOrHandshake() = false, got true")
+			}
+			if a.ShouldRetry() {
+				t.Error("expected ShouldRetry() = false, got true")
+			}
+			if a.ShouldHandshake() {
+				t.Error("expected ShouldHandshake() = false, got true")
+			}
+			if got := a.TimeoutAsDuration(); got != 0 {
+				t.Errorf("expected TimeoutAsDuration() = 0, got %v", got)
+			}
+			if got := a.IntervalAsDuration(); got != 0 {
+				t.Errorf("expected IntervalAsDuration() = 0, got %v", got)
+			}
+		})
+	}
+}
+
+func TestMessage_WithoutAdvice(t *testing.T) {
+	var m Message
+	if err := json.Unmarshal([]byte(`{"channel":"/meta/connect","successful":true}`), &m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if m.Advice.ShouldHandshake() {
+		t.Error("expected ShouldHandshake() = false for a message without advice")
+	}
+	if got := m.Advice.IntervalAsDuration(); got != 0 {
+		t.Errorf("expected IntervalAsDuration() = 0, got %v", got)
+	}
+}
+
+func TestAdvice_MaxNetworkDelayAsDuration(t *testing.T) {
+	a := &Advice{MaxNetworkDelay: 2500}
+	if got, want := a.MaxNetworkDelayAsDuration(), 2500*time.Millisecond; want != got {
+		t.Errorf("expected MaxNetworkDelayAsDuration() = %v, got %v", want, got)
+	}
+	a = nil
+	if got := a.MaxNetworkDelayAsDuration(); got != 0 {
+		t.Errorf("expected MaxNetworkDelayAsDuration() = 0, got %v", got)
+	}
+}
+
+func TestAdvice_UnmarshalJSON(t *testing.T) {
+	var a Advice
+	raw := `{"reconnect":"retry","timeout":30000,"interval":0,"maxNetworkDelay":5000,"multiple-clients":true,"hosts":["a.example.com"]}`
+	if err := json.Unmarshal([]byte(raw), &a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Advice{
+		Reconnect:       ReconnectRetry,
+		Timeout:         30000,
+		MaxNetworkDelay: 5000,
+		MultipleClients: true,
+		Hosts:           []string{"a.example.com"},
+		intervalSet:     true,
+	}
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("expected %+v, got %+v", want, a)
+	}
+
+	var absent Advice
+	if err := json.Unmarshal([]byte(`{"reconnect":"retry"}`), &absent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if absent.intervalSet {
+		t.Error("expected a missing interval not to be recorded as sent")
+	}
+}
+
+func TestAdvice_Merge(t *testing.T) {
+	parse := func(raw string) *Advice {
+		var a Advice
+		if err := json.Unmarshal([]byte(raw), &a); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return &a
+	}
+
+	testCases := []struct {
+		name  string
+		older *Advice
+		newer *Advice
+		want  Advice
+	}{
+		{
+			"defaults",
+			nil,
+			nil,
+			Advice{Reconnect: ReconnectRetry, MaxNetworkDelay: DefaultMaxNetworkDelay},
+		},
+		{
+			"newer over defaults",
+			nil,
+			parse(`{"timeout":30000,"interval":1000}`),
+			Advice{Reconnect: ReconnectRetry, Timeout: 30000, Interval: 1000, MaxNetworkDelay: DefaultMaxNetworkDelay, intervalSet: true},
+		},
+		{
+			"missing fields are inherited",
+			parse(`{"reconnect":"retry","timeout":30000,"interval":1000,"maxNetworkDelay":5000,"hosts":["a"]}`),
+			parse(`{"reconnect":"handshake"}`),
+			Advice{Reconnect: ReconnectHandshake, Timeout: 30000, Interval: 1000, MaxNetworkDelay: 5000, Hosts: []string{"a"}, intervalSet: true},
+		},
+		{
+			"an interval of 0 is kept",
+			parse(`{"interval":1000}`),
+			parse(`{"interval":0}`),
+			Advice{Reconnect: ReconnectRetry, MaxNetworkDelay: DefaultMaxNetworkDelay, intervalSet: true},
+		},
+		{
+			"multiple-clients is not inherited",
+			parse(`{"multiple-clients":true}`),
+			parse(`{"timeout":1000}`),
+			Advice{Reconnect: ReconnectRetry, Timeout: 1000, MaxNetworkDelay: DefaultMaxNetworkDelay},
+		},
+		{
+			"constructed advice",
+			&Advice{Interval: 1000},
+			&Advice{Timeout: 2000},
+			Advice{Reconnect: ReconnectRetry, Timeout: 2000, Interval: 1000, MaxNetworkDelay: DefaultMaxNetworkDelay, intervalSet: true},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.older.Merge(tc.newer)
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, *got)
+			}
+		})
+	}
+}
+
+func TestAdvice_MergeDoesNotModify(t *testing.T) {
+	older := &Advice{Reconnect: ReconnectRetry, Hosts: []string{"a"}}
+	merged := older.Merge(&Advice{Reconnect: ReconnectHandshake})
+	merged.Hosts[0] = "b"
+	if older.Reconnect != ReconnectRetry || older.Hosts[0] != "a" {
+		t.Errorf("expected the older advice to be unchanged, got %+v", older)
+	}
+}
@@ -0,0 +1,46 @@
+package gobayeux
+
+import "time"
+
+// Metrics receives counters and timings for the operations a client
+// performs. It allows exporting them to a system such as Prometheus without
+// gobayeux depending on it. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// IncHandshake is called for every /meta/handshake request
+	IncHandshake()
+	// IncConnect is called for every /meta/connect request
+	IncConnect()
+	// IncSubscribe is called for every /meta/subscribe request
+	IncSubscribe()
+	// IncUnsubscribe is called for every /meta/unsubscribe request
+	IncUnsubscribe()
+	// IncPublish is called for every message published
+	IncPublish()
+	// IncDisconnect is called for every /meta/disconnect request
+	IncDisconnect()
+	// IncReconnect is called whenever the client re-handshakes with the
+	// server after its session was discarded
+	IncReconnect()
+	// IncError is called whenever an operation of the given kind (see the
+	// Operation constants) fails
+	IncError(kind string)
+	// AddMessages is called with the number of messages delivered to
+	// subscribers
+	AddMessages(n int)
+	// ObserveLatency is called with how long an operation of the given kind
+	// took
+	ObserveLatency(kind string, d time.Duration)
+}
+
+type nullMetrics struct{}
+
+func (nullMetrics) IncHandshake()                        {}
+func (nullMetrics) IncConnect()                          {}
+func (nullMetrics) IncSubscribe()                        {}
+func (nullMetrics) IncUnsubscribe()                      {}
+func (nullMetrics) IncPublish()                          {}
+func (nullMetrics) IncDisconnect()                       {}
+func (nullMetrics) IncReconnect()                        {}
+func (nullMetrics) IncError(string)                      {}
+func (nullMetrics) AddMessages(int)                      {}
+func (nullMetrics) ObserveLatency(string, time.Duration) {}
@@ -0,0 +1,127 @@
+package gobayeux_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+type recordingMetrics struct {
+	mu        sync.Mutex
+	counts    map[string]int
+	errors    map[string]int
+	latencies map[string]int
+	messages  int
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		counts:    make(map[string]int),
+		errors:    make(map[string]int),
+		latencies: make(map[string]int),
+	}
+}
+
+func (m *recordingMetrics) inc(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[name]++
+}
+
+func (m *recordingMetrics) IncHandshake()   { m.inc(gobayeux.OperationHandshake) }
+func (m *recordingMetrics) IncConnect()     { m.inc(gobayeux.OperationConnect) }
+func (m *recordingMetrics) IncSubscribe()   { m.inc(gobayeux.OperationSubscribe) }
+func (m *recordingMetrics) IncUnsubscribe() { m.inc(gobayeux.OperationUnsubscribe) }
+func (m *recordingMetrics) IncPublish()     { m.inc(gobayeux.OperationPublish) }
+func (m *recordingMetrics) IncDisconnect()  { m.inc(gobayeux.OperationDisconnect) }
+func (m *recordingMetrics) IncReconnect()   { m.inc("reconnect") }
+
+func (m *recordingMetrics) IncError(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors[kind]++
+}
+
+func (m *recordingMetrics) AddMessages(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages += n
+}
+
+func (m *recordingMetrics) ObserveLatency(kind string, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencies[kind]++
+}
+
+func TestWithMetrics(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.Deny("/foo/denied")
+
+	metrics := newRecordingMetrics()
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithMetrics(metrics),
+		gobayeux.WithIgnoreError(func(err error) bool { return true }),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	msgs := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/denied", msgs)
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the denied subscription")
+	}
+
+	client.Subscribe("/foo/bar", msgs)
+	received := 0
+	for received < 3 {
+		select {
+		case ms := <-msgs:
+			received += len(ms)
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+	}
+	if err := client.PublishSync(ctx, "/foo/bar", json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("failed to publish (%v)", err)
+	}
+	if err := client.Disconnect(ctx); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	for _, kind := range []string{gobayeux.OperationHandshake, gobayeux.OperationConnect, gobayeux.OperationSubscribe, gobayeux.OperationPublish, gobayeux.OperationDisconnect} {
+		if metrics.counts[kind] == 0 {
+			t.Errorf("expected %s to be counted", kind)
+		}
+		if metrics.latencies[kind] == 0 {
+			t.Errorf("expected %s latency to be observed", kind)
+		}
+	}
+	if metrics.errors[gobayeux.OperationSubscribe] != 1 {
+		t.Errorf("expected exactly one subscribe error, got %d", metrics.errors[gobayeux.OperationSubscribe])
+	}
+	if metrics.messages < received {
+		t.Errorf("expected at least %d messages to be counted, got %d", received, metrics.messages)
+	}
+}
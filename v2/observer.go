@@ -0,0 +1,20 @@
+package gobayeux
+
+import "context"
+
+// RequestObserver is notified around every operation BayeuxClient performs
+// against the server. It allows bridging to tracing or metrics libraries
+// (e.g., OpenTelemetry or Prometheus) without gobayeux depending on them.
+type RequestObserver interface {
+	// StartRequest is called before an operation of the given kind (see the
+	// Operation constants) starts. The returned context is used for the
+	// request and the returned function is called with the operation's
+	// result, nil when it succeeds, once it has finished.
+	StartRequest(ctx context.Context, kind string) (context.Context, func(err error))
+}
+
+type nullObserver struct{}
+
+func (nullObserver) StartRequest(ctx context.Context, kind string) (context.Context, func(err error)) {
+	return ctx, func(error) {}
+}
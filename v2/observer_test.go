@@ -0,0 +1,94 @@
+package gobayeux_test
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+type observedRequest struct {
+	kind string
+	err  error
+}
+
+type ctxKey struct{}
+
+type recordingObserver struct {
+	mu       sync.Mutex
+	requests []observedRequest
+}
+
+func (o *recordingObserver) StartRequest(ctx context.Context, kind string) (context.Context, func(error)) {
+	return context.WithValue(ctx, ctxKey{}, kind), func(err error) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		o.requests = append(o.requests, observedRequest{kind: kind, err: err})
+	}
+}
+
+func TestWithRequestObserver(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.Deny("/foo/denied")
+
+	var untracked int
+	transport := roundTripFn(func(r *http.Request) (*http.Response, error) {
+		if r.Context().Value(ctxKey{}) == nil {
+			untracked++
+		}
+		return server.RoundTrip(r)
+	})
+
+	observer := &recordingObserver{}
+	client, err := gobayeux.NewBayeuxClient(nil, transport, "https://example.com", nil, gobayeux.WithRequestObserver(observer))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/denied"}); err == nil {
+		t.Fatal("expected subscribing to a denied channel to fail")
+	}
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+	if _, err := client.Disconnect(ctx); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+
+	want := []struct {
+		kind   string
+		failed bool
+	}{
+		{gobayeux.OperationHandshake, false},
+		{gobayeux.OperationSubscribe, true},
+		{gobayeux.OperationConnect, false},
+		{gobayeux.OperationDisconnect, false},
+	}
+
+	if len(observer.requests) != len(want) {
+		t.Fatalf("expected %d observed requests, got %+v", len(want), observer.requests)
+	}
+	for i, w := range want {
+		got := observer.requests[i]
+		if got.kind != w.kind {
+			t.Errorf("request %d: expected kind %s, got %s", i, w.kind, got.kind)
+		}
+		if failed := got.err != nil; failed != w.failed {
+			t.Errorf("request %d: expected failed to be %v, got err %v", i, w.failed, got.err)
+		}
+	}
+
+	if untracked != 0 {
+		t.Errorf("expected every request to use the observer's context, %d did not", untracked)
+	}
+}
@@ -0,0 +1,44 @@
+package gobayeux
+
+import "encoding/json"
+
+// PayloadRedactor is given a copy of the messages in a request or response
+// and returns the messages to log in their place, e.g., with tokens removed
+// from their Ext.
+type PayloadRedactor func([]Message) []Message
+
+// WithPayloadLogging returns an Option which logs the JSON of every batch of
+// messages sent to and received from the server at the debug level, after
+// passing it through redact, which may be nil. The messages given to redact
+// are a copy, so it is free to modify them.
+func WithPayloadLogging(redact PayloadRedactor) Option {
+	return func(options *Options) {
+		options.PayloadLogging = true
+		options.PayloadRedactor = redact
+	}
+}
+
+// logPayload logs the redacted JSON of ms when payload logging is enabled
+func (b *BayeuxClient) logPayload(logger Logger, msg string, ms []Message) {
+	if !b.logPayloads {
+		return
+	}
+	payload, err := json.Marshal(ms)
+	if err != nil {
+		logger.WithError(err).Debug("cannot log payload")
+		return
+	}
+	if b.redactPayload != nil {
+		// Decoding the payload again gives the redactor a deep copy
+		var redacted []Message
+		if err := json.Unmarshal(payload, &redacted); err != nil {
+			logger.WithError(err).Debug("cannot log payload")
+			return
+		}
+		if payload, err = json.Marshal(b.redactPayload(redacted)); err != nil {
+			logger.WithError(err).Debug("cannot log payload")
+			return
+		}
+	}
+	logger.WithField("payload", string(payload)).Debug(msg)
+}
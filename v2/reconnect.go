@@ -0,0 +1,38 @@
+package gobayeux
+
+import (
+	"math/rand"
+	"time"
+)
+
+// defaultReconnectWindow is the window over which re-handshakes are spread
+// when a reconnect seed has been configured
+const defaultReconnectWindow = 5 * time.Second
+
+// reconnectOffset derives a deterministic delay within window from seed so
+// that a fleet of clients each using a different seed (e.g., a hash of their
+// hostname) do not all re-handshake at the same instant.
+func reconnectOffset(seed int64, window time.Duration) time.Duration {
+	if window <= 0 {
+		return 0
+	}
+	return time.Duration(rand.New(rand.NewSource(seed)).Int63n(int64(window)))
+}
+
+// maxConnectJitter is the largest fraction of the advised interval that
+// WithConnectJitter may add or remove
+const maxConnectJitter = 1.0
+
+// jitterInterval moves interval by up to fraction of itself in either
+// direction, picking the offset with r from [0, 1). fraction is clamped to
+// [0, maxConnectJitter] so the result is never negative and never more than
+// double interval.
+func jitterInterval(interval time.Duration, fraction, r float64) time.Duration {
+	if fraction <= 0 || interval <= 0 {
+		return interval
+	}
+	if fraction > maxConnectJitter {
+		fraction = maxConnectJitter
+	}
+	return interval + time.Duration((2*r-1)*fraction*float64(interval))
+}
@@ -0,0 +1,122 @@
+package gobayeux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReconnectOffset(t *testing.T) {
+	window := 5 * time.Second
+	first := reconnectOffset(1, window)
+	second := reconnectOffset(2, window)
+	if first == second {
+		t.Errorf("expected different seeds to produce different offsets, got %s for both", first)
+	}
+
+	for _, offset := range []time.Duration{first, second} {
+		if offset < 0 || offset >= window {
+			t.Errorf("expected offset within [0, %s), got %s", window, offset)
+		}
+	}
+
+	if again := reconnectOffset(1, window); again != first {
+		t.Errorf("expected the same seed to produce the same offset; want %s got %s", first, again)
+	}
+
+	if offset := reconnectOffset(1, 0); offset != 0 {
+		t.Errorf("expected no offset for an empty window, got %s", offset)
+	}
+}
+
+func TestJitterInterval(t *testing.T) {
+	interval := time.Second
+	testCases := []struct {
+		name     string
+		fraction float64
+		r        float64
+		want     time.Duration
+	}{
+		{"no jitter", 0, 0.9, time.Second},
+		{"shortest", 0.1, 0, 900 * time.Millisecond},
+		{"middle", 0.1, 0.5, time.Second},
+		{"longest", 0.1, 0.999, 1099800 * time.Microsecond},
+		{"capped below", 5, 0, 0},
+		{"capped above", 5, 0.999, 1998 * time.Millisecond},
+		{"negative fraction", -1, 0, time.Second},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			if got := jitterInterval(interval, tc.fraction, tc.r); got != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+
+	if got := jitterInterval(0, 0.5, 0); got != 0 {
+		t.Errorf("expected no wait for an interval of 0, got %s", got)
+	}
+}
+
+func TestWithConnectJitter(t *testing.T) {
+	transport := transportFn(func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			reply := Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true}
+			if m.Channel == MetaConnect {
+				reply.Advice = &Advice{Reconnect: "retry", Interval: 60000}
+			}
+			replies = append(replies, reply)
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	clock := newFakeClock()
+	client, err := NewClient("https://example.com",
+		WithHTTPTransport(transport),
+		WithConnectJitter(0.1),
+		withClock(clock),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	for i := 0; i < 5; i++ {
+		select {
+		case <-clock.added:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the client to wait for the advised interval")
+		}
+
+		clock.lock.Lock()
+		wait := clock.waiters[len(clock.waiters)-1].deadline.Sub(clock.now)
+		clock.lock.Unlock()
+		if wait < 54*time.Second || wait > 66*time.Second {
+			t.Errorf("expected a wait within 10%% of 60s, got %s", wait)
+		}
+		clock.Advance(wait)
+	}
+}
@@ -0,0 +1,62 @@
+package gobayeux
+
+import "time"
+
+// subscriptionRenewal tracks when each active subscription was last sent to
+// the server so that it can be renewed before the server expires it
+type subscriptionRenewal struct {
+	defaultInterval time.Duration
+	intervals       map[Channel]time.Duration
+	renewed         map[Channel]time.Time
+}
+
+func newSubscriptionRenewal(defaultInterval time.Duration, intervals map[Channel]time.Duration) *subscriptionRenewal {
+	return &subscriptionRenewal{
+		defaultInterval: defaultInterval,
+		intervals:       intervals,
+		renewed:         make(map[Channel]time.Time),
+	}
+}
+
+func (r *subscriptionRenewal) interval(channel Channel) time.Duration {
+	if interval, ok := r.intervals[channel]; ok {
+		return interval
+	}
+	return r.defaultInterval
+}
+
+// TickInterval is how often Due should be checked. It is half the shortest
+// configured interval so renewals are never more than half an interval late,
+// or zero if renewal is disabled.
+func (r *subscriptionRenewal) TickInterval() time.Duration {
+	shortest := r.defaultInterval
+	for _, interval := range r.intervals {
+		if interval > 0 && (shortest <= 0 || interval < shortest) {
+			shortest = interval
+		}
+	}
+	return shortest / 2
+}
+
+// Track records that channel was (re)subscribed at now
+func (r *subscriptionRenewal) Track(channel Channel, now time.Time) {
+	if r.interval(channel) > 0 {
+		r.renewed[channel] = now
+	}
+}
+
+// Forget stops renewing channel
+func (r *subscriptionRenewal) Forget(channel Channel) {
+	delete(r.renewed, channel)
+}
+
+// Due returns the channels whose renewal interval has elapsed as of now
+func (r *subscriptionRenewal) Due(now time.Time) []Channel {
+	due := make([]Channel, 0)
+	for channel, renewed := range r.renewed {
+		if now.Sub(renewed) >= r.interval(channel) {
+			due = append(due, channel)
+		}
+	}
+	return due
+}
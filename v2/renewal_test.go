@@ -0,0 +1,110 @@
+package gobayeux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionRenewal(t *testing.T) {
+	r := newSubscriptionRenewal(time.Minute, map[Channel]time.Duration{
+		"/foo/fast":  10 * time.Second,
+		"/foo/never": 0,
+	})
+
+	if got, want := r.TickInterval(), 5*time.Second; got != want {
+		t.Errorf("expected tick interval %s, got %s", want, got)
+	}
+
+	start := time.Now()
+	for _, channel := range []Channel{"/foo/slow", "/foo/fast", "/foo/never"} {
+		r.Track(channel, start)
+	}
+
+	if due := r.Due(start.Add(5 * time.Second)); len(due) != 0 {
+		t.Errorf("expected no renewals to be due yet, got %v", due)
+	}
+	if due := r.Due(start.Add(10 * time.Second)); len(due) != 1 || due[0] != "/foo/fast" {
+		t.Errorf("expected only /foo/fast to be due, got %v", due)
+	}
+	if due := r.Due(start.Add(time.Minute)); len(due) != 2 {
+		t.Errorf("expected /foo/fast and /foo/slow to be due, got %v", due)
+	}
+
+	r.Forget("/foo/slow")
+	if due := r.Due(start.Add(time.Minute)); len(due) != 1 || due[0] != "/foo/fast" {
+		t.Errorf("expected forgotten channel to not be renewed, got %v", due)
+	}
+}
+
+func TestSubscriptionRenewalDisabled(t *testing.T) {
+	r := newSubscriptionRenewal(0, nil)
+	if tick := r.TickInterval(); tick != 0 {
+		t.Errorf("expected renewal to be disabled, got tick interval %s", tick)
+	}
+}
+
+func TestSubscriptionRenewalUsesClock(t *testing.T) {
+	var subscribes int32
+	transport := transportFn(func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			reply := Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true}
+			switch m.Channel {
+			case MetaSubscribe:
+				atomic.AddInt32(&subscribes, 1)
+			case MetaConnect:
+				reply.Advice = &Advice{Reconnect: ReconnectRetry, Interval: int(time.Hour.Milliseconds())}
+			}
+			replies = append(replies, reply)
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	})
+
+	clock := newFakeClock()
+	client, err := NewClient("https://example.com",
+		WithHTTPTransport(transport),
+		WithSubscriptionRenewal(time.Minute),
+		withClock(clock),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.Subscribe("/foo/bar", make(chan []Message)); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+
+	// Only the fake clock moves so the subscription is renewed once it says
+	// a minute has passed
+	timeout := time.After(5 * time.Second)
+	for atomic.LoadInt32(&subscribes) < 2 {
+		select {
+		case <-time.After(time.Millisecond):
+			clock.Advance(30 * time.Second)
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-timeout:
+			t.Fatalf("expected the subscription to be renewed, saw %d subscribe requests", atomic.LoadInt32(&subscribes))
+		}
+	}
+}
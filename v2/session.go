@@ -0,0 +1,93 @@
+package gobayeux
+
+import (
+	"sort"
+	"time"
+)
+
+// ackExtensionName is the ext key the server uses to accept the CometD
+// acknowledgement extension during the handshake
+const ackExtensionName = "ack"
+
+// SessionInfo describes the session negotiated with the server
+type SessionInfo struct {
+	// ClientID is the identifier the server assigned during the handshake
+	ClientID string
+	// ConnectionType is the connection type used for /meta/connect requests
+	ConnectionType string
+	// Interval is the most recent interval advised by the server
+	Interval time.Duration
+	// Timeout is the most recent timeout advised by the server
+	Timeout time.Duration
+	// SupportedConnectionTypes are the connection types the server listed
+	// in its handshake response
+	SupportedConnectionTypes []string
+	// Extensions are the names of the extensions the server included in
+	// the ext field of its handshake response, sorted
+	Extensions []string
+	// AckEnabled reports whether the server accepted the acknowledgement
+	// extension
+	AckEnabled bool
+}
+
+// newSessionInfo builds the SessionInfo for a successful handshake response
+// to a client using connectionType
+func newSessionInfo(m Message, connectionType string) SessionInfo {
+	session := SessionInfo{
+		ClientID:                 m.ClientID,
+		ConnectionType:           connectionType,
+		SupportedConnectionTypes: append([]string(nil), m.SupportedConnectionTypes...),
+	}
+	if len(m.Ext) > 0 {
+		session.Extensions = make([]string, 0, len(m.Ext))
+		for name := range m.Ext {
+			session.Extensions = append(session.Extensions, name)
+		}
+		sort.Strings(session.Extensions)
+	}
+	session.AckEnabled, _ = m.Ext[ackExtensionName].(bool)
+	return session
+}
+
+// observeAdvice records the interval and timeout from the server's advice,
+// merged with the advice it gave before
+func (s *SessionInfo) observeAdvice(advice *Advice) {
+	if advice == nil {
+		return
+	}
+	if advice.Timeout > 0 {
+		s.Timeout = advice.TimeoutAsDuration()
+	}
+	s.Interval = advice.IntervalAsDuration()
+}
+
+// copy returns a SessionInfo which shares no slices with s
+func (s SessionInfo) copy() SessionInfo {
+	s.SupportedConnectionTypes = append([]string(nil), s.SupportedConnectionTypes...)
+	s.Extensions = append([]string(nil), s.Extensions...)
+	return s
+}
+
+// SessionInfo returns the parameters negotiated with the server during the
+// handshake, updated with the advice from each /meta/connect response. It
+// is the zero value before the first successful handshake.
+func (b *BayeuxClient) SessionInfo() SessionInfo {
+	return b.state.GetSession()
+}
+
+// SessionInfo returns the parameters negotiated with the server. See
+// BayeuxClient.SessionInfo.
+func (c *Client) SessionInfo() SessionInfo {
+	return c.client.SessionInfo()
+}
+
+// ClientID returns the ID the server assigned the current session, e.g., to
+// correlate with the server's logs. It is empty while the client is not
+// connected, i.e., before the handshake completes and after Disconnect, and
+// changes whenever the client re-handshakes.
+func (c *Client) ClientID() string {
+	if c.isClosed() || !c.client.stateMachine.IsConnected() {
+		return ""
+	}
+	return c.client.state.GetClientID()
+}
@@ -0,0 +1,98 @@
+package gobayeux_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/extensions/ack"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+func TestSessionInfo(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	for _, ext := range []gobayeux.MessageExtender{ack.New(), gobayeuxtest.NewReplayExtension()} {
+		if err := client.UseExtension(ext); err != nil {
+			t.Fatalf("failed to register extension (%v)", err)
+		}
+	}
+	if got := client.SessionInfo(); !reflect.DeepEqual(got, gobayeux.SessionInfo{}) {
+		t.Errorf("expected no session before handshaking, got %+v", got)
+	}
+
+	ctx := context.Background()
+	ms, err := client.Handshake(ctx)
+	if err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 10000, Interval: 500})
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("failed to connect (%v)", err)
+	}
+
+	want := gobayeux.SessionInfo{
+		ClientID:                 ms[0].ClientID,
+		ConnectionType:           gobayeux.ConnectionTypeLongPolling,
+		Interval:                 500 * time.Millisecond,
+		Timeout:                  10 * time.Second,
+		SupportedConnectionTypes: []string{gobayeux.ConnectionTypeLongPolling},
+		Extensions:               []string{ack.ExtensionName, gobayeuxtest.ReplayExtensionName},
+		AckEnabled:               true,
+	}
+	got := client.SessionInfo()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected session %+v, got %+v", want, got)
+	}
+
+	got.Extensions[0] = "modified"
+	if again := client.SessionInfo(); !reflect.DeepEqual(again, want) {
+		t.Errorf("expected SessionInfo to return a copy, got %+v", again)
+	}
+}
+
+func TestClientID(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if id := client.ClientID(); id != "" {
+		t.Errorf("expected no client ID before connecting, got %q", id)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	id := client.ClientID()
+	if id == "" {
+		t.Fatal("expected a client ID once connected")
+	}
+	if want := client.SessionInfo().ClientID; id != want {
+		t.Errorf("expected the session's client ID %q, got %q", want, id)
+	}
+
+	if err := client.Disconnect(ctx); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+	if id := client.ClientID(); id != "" {
+		t.Errorf("expected no client ID after disconnecting, got %q", id)
+	}
+}
@@ -17,6 +17,9 @@ func (w *wrappedSlog) WithField(key string, value any) Logger {
 	return &wrappedSlog{w.With(slog.Any(key, value))}
 }
 
+// WithSlogLogger returns an Option with logger. Fields added with WithField
+// and WithError become slog attributes and each method logs at the slog
+// level of the same name.
 func WithSlogLogger(logger *slog.Logger) Option {
 	return func(options *Options) {
 		options.Logger = &wrappedSlog{logger}
@@ -4,20 +4,19 @@
 package gobayeux_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"log/slog"
 	"net/http"
 	"os"
+	"testing"
+	"time"
 
 	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
 )
 
-type roundTripFn func(*http.Request) (*http.Response, error)
-
-func (fn roundTripFn) RoundTrip(r *http.Request) (*http.Response, error) {
-	return fn(r)
-}
-
 func ExampleWithSlogLogger() {
 	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
@@ -32,8 +31,8 @@ func ExampleWithSlogLogger() {
 
 	handler := roundTripFn(func(r *http.Request) (*http.Response, error) {
 		return &http.Response{
-			StatusCode: http.StatusOK,
-			Status:     http.StatusText(http.StatusOK),
+			StatusCode: http.StatusInternalServerError,
+			Status:     http.StatusText(http.StatusInternalServerError),
 		}, nil
 	})
 
@@ -52,5 +51,70 @@ func ExampleWithSlogLogger() {
 	}
 	// Output:
 	// level=DEBUG msg=starting at=handshake
-	// level=DEBUG msg="error parsing response" at=handshake error=EOF
+	// level=DEBUG msg="error parsing response" at=handshake error="handshake failed: 500 Internal Server Error"
+}
+
+func TestWithSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithSlogLogger(logger),
+		gobayeux.WithHTTPTransport(server),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	msgs := make(chan []gobayeux.Message, 10)
+	if err := client.Subscribe("/foo/bar", msgs); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	errs := client.Start(ctx)
+	select {
+	case <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for messages")
+	}
+	if err := client.Disconnect(ctx); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+
+	clientID := client.SessionInfo().ClientID
+	var received, delivered bool
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var entry map[string]any
+		if err := decoder.Decode(&entry); err != nil {
+			t.Fatalf("failed to decode log entry (%v)", err)
+		}
+		if entry["level"] != "DEBUG" {
+			continue
+		}
+		switch entry["msg"] {
+		case "received message":
+			if entry["at"] == gobayeux.OperationConnect && entry["channel"] == "/foo/bar" &&
+				entry["clientID"] == clientID && entry["id"] != "" {
+				received = true
+			}
+		case "delivering message":
+			if entry["channel"] == "/foo/bar" && entry["clientID"] == clientID {
+				delivered = true
+			}
+		}
+	}
+	if !received {
+		t.Errorf("expected a received message entry with the connect fields, got\n%s", buf.String())
+	}
+	if !delivered {
+		t.Errorf("expected a delivering message entry with the delivery fields, got\n%s", buf.String())
+	}
 }
@@ -20,6 +20,10 @@ const (
 	connectedRepr   StateRepresentation = "CONNECTED"
 )
 
+// BackoffState is reported by Client.State while the client is waiting
+// before attempting to reconnect
+const BackoffState StateRepresentation = "BACKOFF"
+
 var stateNames = []StateRepresentation{unconnectedRepr, connectingRepr, connectedRepr}
 
 func stateName(state int32) string {
@@ -0,0 +1,270 @@
+package gobayeux
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of the counters a Client keeps about its operation
+type Stats struct {
+	// Handshakes, Connects, Subscribes, Unsubscribes, and Disconnects count
+	// the requests made on each of the corresponding meta channels
+	Handshakes   uint64
+	Connects     uint64
+	Subscribes   uint64
+	Unsubscribes uint64
+	Disconnects  uint64
+	// Publishes counts the messages published
+	Publishes uint64
+	// Reconnects counts the re-handshakes after the session was discarded
+	Reconnects uint64
+	// Errors counts failed operations by kind (see the Operation constants)
+	Errors map[string]uint64
+	// MessagesReceived counts the messages delivered to subscribers
+	MessagesReceived uint64
+	// Latency holds the total time spent on, and the number of, requests by
+	// kind
+	Latency map[string]LatencyStats
+	// Subscriptions is the number of channels currently subscribed to
+	Subscriptions int
+	// LastConnect is when the most recent successful /meta/connect
+	// completed
+	LastConnect time.Time
+	// LastAdvice is the most recent advice in a /meta/connect reply or nil
+	// if the server has not given any
+	LastAdvice *Advice
+	// ClientID is the ID the server assigned the current session
+	ClientID string
+}
+
+// LatencyStats sums the time spent on requests of one kind
+type LatencyStats struct {
+	Count uint64
+	Total time.Duration
+}
+
+// statsCollector implements Metrics to keep the counters behind Stats. It is
+// combined with the user's Metrics so both see every event.
+type statsCollector struct {
+	mu    sync.Mutex
+	stats Stats
+}
+
+func newStatsCollector() *statsCollector {
+	return &statsCollector{stats: Stats{
+		Errors:  make(map[string]uint64),
+		Latency: make(map[string]LatencyStats),
+	}}
+}
+
+func (s *statsCollector) update(fn func(*Stats)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.stats)
+}
+
+func (s *statsCollector) IncHandshake()   { s.update(func(st *Stats) { st.Handshakes++ }) }
+func (s *statsCollector) IncConnect()     { s.update(func(st *Stats) { st.Connects++ }) }
+func (s *statsCollector) IncSubscribe()   { s.update(func(st *Stats) { st.Subscribes++ }) }
+func (s *statsCollector) IncUnsubscribe() { s.update(func(st *Stats) { st.Unsubscribes++ }) }
+func (s *statsCollector) IncPublish()     { s.update(func(st *Stats) { st.Publishes++ }) }
+func (s *statsCollector) IncDisconnect()  { s.update(func(st *Stats) { st.Disconnects++ }) }
+func (s *statsCollector) IncReconnect()   { s.update(func(st *Stats) { st.Reconnects++ }) }
+
+func (s *statsCollector) IncError(kind string) {
+	s.update(func(st *Stats) { st.Errors[kind]++ })
+}
+
+func (s *statsCollector) AddMessages(n int) {
+	s.update(func(st *Stats) { st.MessagesReceived += uint64(n) })
+}
+
+func (s *statsCollector) ObserveLatency(kind string, d time.Duration) {
+	s.update(func(st *Stats) {
+		latency := st.Latency[kind]
+		latency.Count++
+		latency.Total += d
+		st.Latency[kind] = latency
+	})
+}
+
+// observeConnect records a successful /meta/connect and its replies' advice
+func (s *statsCollector) observeConnect(at time.Time, ms []Message) {
+	s.update(func(st *Stats) {
+		st.LastConnect = at
+		for _, m := range ms {
+			if m.Channel == MetaConnect && m.Advice != nil {
+				advice := *m.Advice
+				st.LastAdvice = &advice
+			}
+		}
+	})
+}
+
+// snapshot returns a copy of the counters which is safe to hold on to
+func (s *statsCollector) snapshot() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.Errors = make(map[string]uint64, len(s.stats.Errors))
+	for kind, n := range s.stats.Errors {
+		stats.Errors[kind] = n
+	}
+	stats.Latency = make(map[string]LatencyStats, len(s.stats.Latency))
+	for kind, latency := range s.stats.Latency {
+		stats.Latency[kind] = latency
+	}
+	if s.stats.LastAdvice != nil {
+		advice := *s.stats.LastAdvice
+		stats.LastAdvice = &advice
+	}
+	return stats
+}
+
+// Stats returns a snapshot of the client's counters and connection details,
+// e.g., for a health or debug endpoint. It is safe to call at any time.
+func (c *Client) Stats() Stats {
+	stats := c.stats.snapshot()
+	stats.Subscriptions = c.subscriptions.Len()
+	stats.ClientID = c.client.state.GetClientID()
+	return stats
+}
+
+// multiMetrics reports to each of its Metrics in turn
+type multiMetrics []Metrics
+
+func (m multiMetrics) IncHandshake() {
+	for _, metrics := range m {
+		metrics.IncHandshake()
+	}
+}
+
+func (m multiMetrics) IncConnect() {
+	for _, metrics := range m {
+		metrics.IncConnect()
+	}
+}
+
+func (m multiMetrics) IncSubscribe() {
+	for _, metrics := range m {
+		metrics.IncSubscribe()
+	}
+}
+
+func (m multiMetrics) IncUnsubscribe() {
+	for _, metrics := range m {
+		metrics.IncUnsubscribe()
+	}
+}
+
+func (m multiMetrics) IncPublish() {
+	for _, metrics := range m {
+		metrics.IncPublish()
+	}
+}
+
+func (m multiMetrics) IncDisconnect() {
+	for _, metrics := range m {
+		metrics.IncDisconnect()
+	}
+}
+
+func (m multiMetrics) IncReconnect() {
+	for _, metrics := range m {
+		metrics.IncReconnect()
+	}
+}
+
+func (m multiMetrics) IncError(kind string) {
+	for _, metrics := range m {
+		metrics.IncError(kind)
+	}
+}
+
+func (m multiMetrics) AddMessages(n int) {
+	for _, metrics := range m {
+		metrics.AddMessages(n)
+	}
+}
+
+func (m multiMetrics) ObserveLatency(kind string, d time.Duration) {
+	for _, metrics := range m {
+		metrics.ObserveLatency(kind, d)
+	}
+}
+
+// WriteMetrics writes the client's current Stats to w in the OpenMetrics
+// text format so they can be served for scraping without depending on a
+// metrics library.
+//
+// See also: https://github.com/OpenObservability/OpenMetrics/blob/main/specification/OpenMetrics.md
+func (c *Client) WriteMetrics(w io.Writer) error {
+	return c.Stats().writeOpenMetrics(w)
+}
+
+func (s Stats) writeOpenMetrics(w io.Writer) error {
+	ew := &errWriter{w: w}
+
+	counter := func(name, help string, value uint64) {
+		ew.printf("# TYPE gobayeux_%s counter\n", name)
+		ew.printf("# HELP gobayeux_%s %s\n", name, help)
+		ew.printf("gobayeux_%s_total %d\n", name, value)
+	}
+	counter("handshakes", "Requests sent to /meta/handshake.", s.Handshakes)
+	counter("connects", "Requests sent to /meta/connect.", s.Connects)
+	counter("subscribes", "Requests sent to /meta/subscribe.", s.Subscribes)
+	counter("unsubscribes", "Requests sent to /meta/unsubscribe.", s.Unsubscribes)
+	counter("disconnects", "Requests sent to /meta/disconnect.", s.Disconnects)
+	counter("publishes", "Messages published.", s.Publishes)
+	counter("reconnects", "Re-handshakes after the session was discarded.", s.Reconnects)
+	counter("messages_received", "Messages delivered to subscribers.", s.MessagesReceived)
+
+	ew.printf("# TYPE gobayeux_errors counter\n")
+	ew.printf("# HELP gobayeux_errors Failed operations.\n")
+	for _, kind := range sortedKeys(s.Errors) {
+		ew.printf("gobayeux_errors_total{operation=%q} %d\n", kind, s.Errors[kind])
+	}
+
+	ew.printf("# TYPE gobayeux_request_duration_seconds summary\n")
+	ew.printf("# UNIT gobayeux_request_duration_seconds seconds\n")
+	ew.printf("# HELP gobayeux_request_duration_seconds Time spent on requests.\n")
+	for _, kind := range sortedKeys(s.Latency) {
+		latency := s.Latency[kind]
+		ew.printf("gobayeux_request_duration_seconds_sum{operation=%q} %g\n", kind, latency.Total.Seconds())
+		ew.printf("gobayeux_request_duration_seconds_count{operation=%q} %d\n", kind, latency.Count)
+	}
+
+	ew.printf("# TYPE gobayeux_subscriptions gauge\n")
+	ew.printf("# HELP gobayeux_subscriptions Channels currently subscribed to.\n")
+	ew.printf("gobayeux_subscriptions %d\n", s.Subscriptions)
+
+	ew.printf("# EOF\n")
+	return ew.err
+}
+
+// errWriter holds on to the first error from w so that writing can carry on
+// without checking each call
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err != nil {
+		return
+	}
+	_, ew.err = fmt.Fprintf(ew.w, format, args...)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,181 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+var (
+	openMetricsMetadata = regexp.MustCompile(`^# (TYPE|HELP|UNIT) ([a-zA-Z_:][a-zA-Z0-9_:]*) (.+)$`)
+	openMetricsSample   = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(\{[a-zA-Z_][a-zA-Z0-9_]*="[^"\\]*"(,[a-zA-Z_][a-zA-Z0-9_]*="[^"\\]*")*\})? (\S+)$`)
+)
+
+// parseOpenMetrics checks that text follows the OpenMetrics text format
+// closely enough for a scraper and returns the value of every sample
+func parseOpenMetrics(t *testing.T, text string) map[string]float64 {
+	t.Helper()
+
+	if !strings.HasSuffix(text, "# EOF\n") {
+		t.Fatalf("expected the exposition to end with # EOF, got %q", text)
+	}
+
+	types := make(map[string]string)
+	samples := make(map[string]float64)
+	for _, line := range strings.Split(strings.TrimSuffix(text, "# EOF\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if m := openMetricsMetadata.FindStringSubmatch(line); m != nil {
+			if m[1] == "TYPE" {
+				types[m[2]] = m[3]
+			}
+			continue
+		}
+
+		m := openMetricsSample.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("invalid OpenMetrics line %q", line)
+		}
+		family := m[1]
+		for _, suffix := range []string{"_total", "_sum", "_count"} {
+			if _, ok := types[family]; !ok {
+				family = strings.TrimSuffix(family, suffix)
+			}
+		}
+		if _, ok := types[family]; !ok {
+			t.Fatalf("sample %q has no TYPE declared before it", line)
+		}
+		value, err := strconv.ParseFloat(m[4], 64)
+		if err != nil {
+			t.Fatalf("invalid value in %q (%v)", line, err)
+		}
+		samples[m[1]+m[2]] = value
+	}
+	return samples
+}
+
+func TestClientWriteMetrics(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.Deny("/foo/denied")
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithIgnoreError(func(err error) bool { return true }),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	msgs := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/denied", msgs)
+	select {
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the denied subscription")
+	}
+
+	client.Subscribe("/foo/bar", msgs)
+	select {
+	case <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for messages")
+	}
+
+	var buf bytes.Buffer
+	if err := client.WriteMetrics(&buf); err != nil {
+		t.Fatalf("failed to write metrics (%v)", err)
+	}
+	samples := parseOpenMetrics(t, buf.String())
+
+	for _, name := range []string{"gobayeux_handshakes_total", "gobayeux_connects_total", "gobayeux_messages_received_total"} {
+		if samples[name] < 1 {
+			t.Errorf("expected %s to be at least 1, got %v", name, samples[name])
+		}
+	}
+	if got := samples["gobayeux_subscribes_total"]; got != 2 {
+		t.Errorf("expected 2 subscribe requests, got %v", got)
+	}
+	if got := samples[`gobayeux_errors_total{operation="subscribe"}`]; got != 1 {
+		t.Errorf("expected 1 subscribe error, got %v", got)
+	}
+	if got := samples[`gobayeux_request_duration_seconds_count{operation="handshake"}`]; got != 1 {
+		t.Errorf("expected 1 timed handshake, got %v", got)
+	}
+	if got := samples["gobayeux_subscriptions"]; got != 1 {
+		t.Errorf("expected 1 active subscription, got %v", got)
+	}
+}
+
+func TestClientStats(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Interval: 0, Timeout: 1234})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if stats := client.Stats(); stats.MessagesReceived != 0 || !stats.LastConnect.IsZero() || stats.LastAdvice != nil {
+		t.Errorf("expected empty stats before starting, got %+v", stats)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := client.Start(ctx)
+
+	msgs := make(chan []gobayeux.Message, 10)
+	client.Subscribe("/foo/bar", msgs)
+
+	var received uint64
+	var last gobayeux.Stats
+	for i := 0; i < 3; i++ {
+		select {
+		case ms := <-msgs:
+			received += uint64(len(ms))
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for messages")
+		}
+
+		stats := client.Stats()
+		// Batches waiting in msgs are counted as soon as they are delivered
+		if stats.MessagesReceived < received || stats.MessagesReceived < last.MessagesReceived {
+			t.Errorf("expected MessagesReceived to count at least the %d messages received, got %d", received, stats.MessagesReceived)
+		}
+		if stats.LastConnect.Before(last.LastConnect) || stats.LastConnect.IsZero() {
+			t.Errorf("expected LastConnect to move forward from %s, got %s", last.LastConnect, stats.LastConnect)
+		}
+		last = stats
+	}
+
+	if last.ClientID == "" || last.ClientID != client.SessionInfo().ClientID {
+		t.Errorf("expected ClientID %q, got %q", client.SessionInfo().ClientID, last.ClientID)
+	}
+	if last.LastAdvice == nil || last.LastAdvice.Timeout != 1234 {
+		t.Errorf("expected the server's advice, got %+v", last.LastAdvice)
+	}
+	if last.Subscriptions != 1 {
+		t.Errorf("expected 1 active subscription, got %d", last.Subscriptions)
+	}
+}
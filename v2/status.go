@@ -0,0 +1,61 @@
+package gobayeux
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// StatusRecovery is how the Client recovers when a /meta/connect request
+// fails with an unexpected HTTP status
+type StatusRecovery int
+
+const (
+	// FailOnStatus stops the Client, returning the error from Start. This is
+	// what happens for any status without a StatusAction.
+	FailOnStatus StatusRecovery = iota
+	// RetryOnStatus connects again once the StatusAction's Backoff has
+	// elapsed
+	RetryOnStatus
+	// RehandshakeOnStatus discards the session and handshakes with the
+	// server again
+	RehandshakeOnStatus
+)
+
+// StatusAction configures how the Client handles a BadResponseError with a
+// particular HTTP status code
+type StatusAction struct {
+	// Recovery is what the Client does after Hook returns
+	Recovery StatusRecovery
+	// Backoff is how long to wait before connecting again with
+	// RetryOnStatus
+	Backoff time.Duration
+	// Hook, if set, is called before recovering, e.g., to refresh an
+	// expired token. Returning an error stops the Client.
+	Hook func(ctx context.Context, err BadResponseError) error
+}
+
+// WithStatusHandler returns an Option with the StatusAction to take for each
+// HTTP status code a failed /meta/connect request may return. For example:
+//
+//	gobayeux.WithStatusHandler(map[int]gobayeux.StatusAction{
+//		http.StatusUnauthorized:       {Recovery: gobayeux.RehandshakeOnStatus, Hook: refreshToken},
+//		http.StatusForbidden:          {Recovery: gobayeux.FailOnStatus},
+//		http.StatusServiceUnavailable: {Recovery: gobayeux.RetryOnStatus, Backoff: 5 * time.Second},
+//	})
+func WithStatusHandler(handlers map[int]StatusAction) Option {
+	return func(options *Options) {
+		options.StatusHandlers = handlers
+	}
+}
+
+// statusAction finds the StatusAction configured for err, if err is a
+// BadResponseError
+func (c *Client) statusAction(err error) (StatusAction, BadResponseError, bool) {
+	var badResponse BadResponseError
+	if !errors.As(err, &badResponse) {
+		return StatusAction{}, badResponse, false
+	}
+	action, ok := c.statusHandlers[badResponse.StatusCode]
+	return action, badResponse, ok
+}
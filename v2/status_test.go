@@ -0,0 +1,122 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+// failingConnectServer answers every /meta/connect after the first with
+// statusCode once and counts the requests made on each meta channel
+type failingConnectServer struct {
+	statusCode int
+	handshakes int32
+	connects   int32
+}
+
+func (s *failingConnectServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	reply := `[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`
+	if bytes.Contains(body, []byte(gobayeux.MetaHandshake)) {
+		atomic.AddInt32(&s.handshakes, 1)
+	} else if bytes.Contains(body, []byte(gobayeux.MetaConnect)) {
+		reply = `[{"channel":"/meta/connect","successful":true,"advice":{"reconnect":"retry","interval":10000}}]`
+		if atomic.AddInt32(&s.connects, 1) == 1 {
+			return &http.Response{
+				StatusCode: s.statusCode,
+				Status:     http.StatusText(s.statusCode),
+				Body:       io.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Body:       io.NopCloser(bytes.NewBufferString(reply)),
+	}, nil
+}
+
+func TestWithStatusHandler(t *testing.T) {
+	var refreshed int32
+	handlers := map[int]gobayeux.StatusAction{
+		http.StatusUnauthorized: {
+			Recovery: gobayeux.RehandshakeOnStatus,
+			Hook: func(ctx context.Context, err gobayeux.BadResponseError) error {
+				atomic.AddInt32(&refreshed, 1)
+				return nil
+			},
+		},
+		http.StatusForbidden:          {Recovery: gobayeux.FailOnStatus},
+		http.StatusServiceUnavailable: {Recovery: gobayeux.RetryOnStatus, Backoff: 10 * time.Millisecond},
+	}
+
+	testCases := []struct {
+		name           string
+		statusCode     int
+		wantErr        bool
+		wantHandshakes int32
+		wantRefreshed  int32
+	}{
+		{"401 refreshes and re-handshakes", http.StatusUnauthorized, false, 2, 1},
+		{"403 fails permanently", http.StatusForbidden, true, 1, 0},
+		{"503 retries after backing off", http.StatusServiceUnavailable, false, 1, 0},
+		{"unconfigured status fails", http.StatusBadGateway, true, 1, 0},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&refreshed, 0)
+			server := &failingConnectServer{statusCode: tc.statusCode}
+			client, err := gobayeux.NewClient(
+				"https://example.com",
+				gobayeux.WithHTTPTransport(server),
+				gobayeux.WithStatusHandler(handlers),
+			)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errs := client.Start(ctx)
+
+			select {
+			case err := <-errs:
+				if !tc.wantErr {
+					t.Fatalf("unexpected error from client (%v)", err)
+				}
+				var badResponse gobayeux.BadResponseError
+				if !errors.As(err, &badResponse) || badResponse.StatusCode != tc.statusCode {
+					t.Errorf("expected a BadResponseError with status %d, got %v", tc.statusCode, err)
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tc.wantErr {
+					t.Fatal("expected the client to fail")
+				}
+				if got := atomic.LoadInt32(&server.connects); got != 2 {
+					t.Errorf("expected the client to connect again, got %d connects", got)
+				}
+			}
+
+			if got := atomic.LoadInt32(&server.handshakes); got != tc.wantHandshakes {
+				t.Errorf("expected %d handshakes, got %d", tc.wantHandshakes, got)
+			}
+			if got := atomic.LoadInt32(&refreshed); got != tc.wantRefreshed {
+				t.Errorf("expected the hook to be called %d times, got %d", tc.wantRefreshed, got)
+			}
+		})
+	}
+}
@@ -2,26 +2,73 @@ package gobayeux
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 )
 
 type subscriptionsMap struct {
 	lock sync.RWMutex
-	subs map[Channel]chan []Message
+	subs map[Channel][]chan []Message
 }
 
 func newSubscriptionsMap() *subscriptionsMap {
-	return &subscriptionsMap{subs: make(map[Channel]chan []Message)}
+	return &subscriptionsMap{subs: make(map[Channel][]chan []Message)}
 }
 
 func (sm *subscriptionsMap) Add(channel Channel, ms chan []Message) error {
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
 	if _, ok := sm.subs[channel]; !ok {
-		sm.subs[channel] = ms
+		sm.subs[channel] = []chan []Message{ms}
 		return nil
 	}
-	return fmt.Errorf("channel '%s' already subscribed", channel)
+	return fmt.Errorf("channel '%s': %w", channel, ErrAlreadySubscribed)
+}
+
+// AddReceiver adds another receiver for a channel, whether or not it already
+// has one
+func (sm *subscriptionsMap) AddReceiver(channel Channel, ms chan []Message) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	sm.subs[channel] = append(sm.subs[channel], ms)
+}
+
+// AddListener adds another receiver for a channel which already has at
+// least one, reporting whether it did
+func (sm *subscriptionsMap) AddListener(channel Channel, ms chan []Message) bool {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	if len(sm.subs[channel]) == 0 {
+		return false
+	}
+	sm.subs[channel] = append(sm.subs[channel], ms)
+	return true
+}
+
+// RemoveReceiver removes one receiver for a channel and returns how many are
+// left
+func (sm *subscriptionsMap) RemoveReceiver(channel Channel, ms chan []Message) (int, error) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	receivers := sm.subs[channel]
+	for i, receiver := range receivers {
+		if receiver == ms {
+			// Get hands out the slice so removing must not modify it
+			remaining := make([]chan []Message, 0, len(receivers)-1)
+			remaining = append(remaining, receivers[:i]...)
+			remaining = append(remaining, receivers[i+1:]...)
+			sm.subs[channel] = remaining
+			return len(remaining), nil
+		}
+	}
+	return len(receivers), fmt.Errorf("channel '%s': %w", channel, ErrNotSubscribed)
+}
+
+// Replace drops every receiver for a channel in favour of ms
+func (sm *subscriptionsMap) Replace(channel Channel, ms chan []Message) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	sm.subs[channel] = []chan []Message{ms}
 }
 
 func (sm *subscriptionsMap) Remove(channel Channel) {
@@ -30,12 +77,42 @@ func (sm *subscriptionsMap) Remove(channel Channel) {
 	delete(sm.subs, channel)
 }
 
-func (sm *subscriptionsMap) Get(channel Channel) (chan []Message, error) {
+// Get returns every receiver for a channel. The slice must not be modified.
+func (sm *subscriptionsMap) Get(channel Channel) ([]chan []Message, error) {
 	sm.lock.RLock()
 	defer sm.lock.RUnlock()
 	ms, ok := sm.subs[channel]
 	if !ok {
-		return nil, fmt.Errorf("channel '%s' has no subscriptions", channel)
+		return nil, fmt.Errorf("channel '%s': %w", channel, ErrNotSubscribed)
 	}
 	return ms, nil
 }
+
+// List returns the subscribed channels, in order, excluding meta channels
+// which the Client makes for itself
+func (sm *subscriptionsMap) List() []Channel {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	channels := make([]Channel, 0, len(sm.subs))
+	for channel := range sm.subs {
+		if channel.Type() != MetaChannel {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+	return channels
+}
+
+// Len returns the number of subscriptions excluding those to meta channels
+// which the Client makes for itself
+func (sm *subscriptionsMap) Len() int {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	n := 0
+	for channel := range sm.subs {
+		if channel.Type() != MetaChannel {
+			n++
+		}
+	}
+	return n
+}
@@ -1,6 +1,9 @@
 package gobayeux
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestSubscriptionsMap_Add(t *testing.T) {
 	sm := newSubscriptionsMap()
@@ -11,13 +14,17 @@ func TestSubscriptionsMap_Add(t *testing.T) {
 	}
 
 	got, ok := sm.subs["/foo/bar"]
-	if !ok {
-		t.Error("channel was not registered properly")
+	if !ok || len(got) != 1 {
+		t.Fatal("channel was not registered properly")
 	}
 
-	if want != got {
+	if want != got[0] {
 		t.Error("chan received was not the chan registered")
 	}
+
+	if err := sm.Add("/foo/bar", want); !errors.Is(err, ErrAlreadySubscribed) {
+		t.Errorf("expected ErrAlreadySubscribed adding '/foo/bar' again, got %v", err)
+	}
 }
 
 func TestSubscriptionsMap_Remove(t *testing.T) {
@@ -41,13 +48,13 @@ func TestSubscriptionsMap_Remove(t *testing.T) {
 
 func TestSubscriptionsMap_Get(t *testing.T) {
 	sm := newSubscriptionsMap()
-	if _, err := sm.Get("/foo/bar"); err == nil {
-		t.Error("expected '/foo/bar' to not have a subscription, but had one")
+	if _, err := sm.Get("/foo/bar"); !errors.Is(err, ErrNotSubscribed) {
+		t.Errorf("expected ErrNotSubscribed for '/foo/bar', got %v", err)
 	}
 
 	want := make(chan []Message)
-	sm.subs["/foo/bar"] = want
-	if got, err := sm.Get("/foo/bar"); want != got {
+	sm.subs["/foo/bar"] = []chan []Message{want}
+	if got, err := sm.Get("/foo/bar"); len(got) != 1 || want != got[0] {
 		if err != nil {
 			t.Errorf("expected Get(\"/foo/bar\") to return without error but got %q", err)
 		} else {
@@ -56,6 +63,48 @@ func TestSubscriptionsMap_Get(t *testing.T) {
 	}
 }
 
+func TestSubscriptionsMap_Listeners(t *testing.T) {
+	sm := newSubscriptionsMap()
+	first := make(chan []Message)
+	second := make(chan []Message)
+	if sm.AddListener("/foo/bar", second) {
+		t.Fatal("expected AddListener to need an existing subscription")
+	}
+	if err := sm.Add("/foo/bar", first); err != nil {
+		t.Fatalf("unable to add subscription for test: %q", err)
+	}
+	if !sm.AddListener("/foo/bar", second) {
+		t.Fatal("expected AddListener to add to the existing subscription")
+	}
+
+	got, _ := sm.Get("/foo/bar")
+	if remaining, err := sm.RemoveReceiver("/foo/bar", first); err != nil || remaining != 1 {
+		t.Errorf("expected 1 receiver left without error, got %d (%v)", remaining, err)
+	}
+	if len(got) != 2 || got[0] != first {
+		t.Error("expected RemoveReceiver to leave slices returned by Get unchanged")
+	}
+	if got, _ := sm.Get("/foo/bar"); len(got) != 1 || got[0] != second {
+		t.Errorf("expected only the second receiver to be left, got %v", got)
+	}
+	if _, err := sm.RemoveReceiver("/foo/bar", first); !errors.Is(err, ErrNotSubscribed) {
+		t.Errorf("expected ErrNotSubscribed removing a receiver twice, got %v", err)
+	}
+}
+
+func TestSubscriptionsMap_List(t *testing.T) {
+	sm := newSubscriptionsMap()
+	for _, channel := range []Channel{"/foo/b", MetaConnect, "/foo/a"} {
+		if err := sm.Add(channel, nil); err != nil {
+			t.Fatalf("unable to add subscription for test: %q", err)
+		}
+	}
+
+	if got := sm.List(); len(got) != 2 || got[0] != "/foo/a" || got[1] != "/foo/b" {
+		t.Errorf("expected [/foo/a /foo/b], got %v", got)
+	}
+}
+
 func BenchmarkSubscriptionsMapAddToEmpty(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		sm := newSubscriptionsMap()
@@ -66,7 +115,7 @@ func BenchmarkSubscriptionsMapAddToEmpty(b *testing.B) {
 func BenchmarkSubscriptionsMapAddNewToNonEmpty(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		sm := &subscriptionsMap{
-			subs: map[Channel](chan []Message){
+			subs: map[Channel][]chan []Message{
 				"/":           nil,
 				"/foo":        nil,
 				"/bar":        nil,
@@ -97,7 +146,30 @@ func BenchmarkSubscriptionsMapAddNewToNonEmpty(b *testing.B) {
 
 func BenchmarkSubscriptionsMapAddDuplicate(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		sm := &subscriptionsMap{subs: map[Channel](chan []Message){"/foo/bar": nil}}
+		sm := &subscriptionsMap{subs: map[Channel][]chan []Message{"/foo/bar": {nil}}}
 		_ = sm.Add("/foo/bar", nil)
 	}
 }
+
+func TestSubscriptionsMap_AddReceiver(t *testing.T) {
+	sm := newSubscriptionsMap()
+	first := make(chan []Message)
+	second := make(chan []Message)
+	if err := sm.Add("/foo/bar", first); err != nil {
+		t.Fatalf("unable to add subscription for test: %q", err)
+	}
+	sm.AddReceiver("/foo/bar", second)
+
+	got, err := sm.Get("/foo/bar")
+	if err != nil {
+		t.Fatalf("expected Get(\"/foo/bar\") to return without error but got %q", err)
+	}
+	if len(got) != 2 || got[0] != first || got[1] != second {
+		t.Errorf("expected both receivers in the order added, got %v", got)
+	}
+
+	sm.Replace("/foo/bar", first)
+	if got, _ := sm.Get("/foo/bar"); len(got) != 1 || got[0] != first {
+		t.Errorf("expected only the replacement receiver, got %v", got)
+	}
+}
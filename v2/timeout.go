@@ -0,0 +1,76 @@
+package gobayeux
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// WithMetaTimeout returns an Option which limits how long each handshake,
+// subscribe, unsubscribe, and disconnect request may take. Unlike
+// http.Client.Timeout it does not apply to /meta/connect, which the server
+// holds open for as long as its advised timeout.
+func WithMetaTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.MetaTimeout = timeout
+	}
+}
+
+// WithHandshakeTimeout returns an Option which limits how long each
+// /meta/handshake request may take, so that a server which accepts the
+// connection but never replies does not stall Client.Start. It takes
+// precedence over WithMetaTimeout for handshakes.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(options *Options) {
+		options.HandshakeTimeout = timeout
+	}
+}
+
+// WithConnectTimeout returns an Option which limits how long each
+// /meta/connect request may take to the timeout most recently advised by the
+// server plus the given allowance for network delays.
+func WithConnectTimeout(allowance time.Duration) Option {
+	return func(options *Options) {
+		options.ConnectTimeout = allowance
+	}
+}
+
+// operationTimeouts holds the deadlines applied to each kind of request
+type operationTimeouts struct {
+	meta      time.Duration
+	handshake time.Duration
+	connect   time.Duration
+	// advised is the most recent timeout advised by the server, in
+	// nanoseconds
+	advised int64
+}
+
+// withTimeout applies the timeout for the given kind of request to ctx
+func (t *operationTimeouts) withTimeout(ctx context.Context, kind string) (context.Context, context.CancelFunc) {
+	timeout := t.meta
+	switch kind {
+	case OperationHandshake:
+		if t.handshake > 0 {
+			timeout = t.handshake
+		}
+	case OperationConnect:
+		timeout = 0
+		if t.connect > 0 {
+			timeout = time.Duration(atomic.LoadInt64(&t.advised)) + t.connect
+		}
+	}
+
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// observeAdvice records the timeout advised in any of the messages
+func (t *operationTimeouts) observeAdvice(ms []Message) {
+	for _, m := range ms {
+		if m.Advice != nil && m.Advice.Timeout > 0 {
+			atomic.StoreInt64(&t.advised, int64(m.Advice.TimeoutAsDuration()))
+		}
+	}
+}
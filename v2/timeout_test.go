@@ -0,0 +1,161 @@
+package gobayeux_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+// deadlineServer records how long each request had left before its deadline
+type deadlineServer struct {
+	mu        sync.Mutex
+	remaining map[gobayeux.Channel]time.Duration
+}
+
+func (s *deadlineServer) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := gobayeux.MetaHandshake
+	reply := `[{"channel":"/meta/handshake","clientId":"abc","successful":true,"advice":{"reconnect":"retry","timeout":20000}}]`
+	switch {
+	case bytes.Contains(body, []byte(gobayeux.MetaConnect)):
+		channel = gobayeux.MetaConnect
+		reply = `[{"channel":"/meta/connect","successful":true}]`
+	case bytes.Contains(body, []byte(gobayeux.MetaSubscribe)):
+		channel = gobayeux.MetaSubscribe
+		reply = `[{"channel":"/meta/subscribe","subscription":"/foo/bar","successful":true}]`
+	}
+
+	s.mu.Lock()
+	if deadline, ok := r.Context().Deadline(); ok {
+		s.remaining[channel] = time.Until(deadline)
+	}
+	s.mu.Unlock()
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     http.StatusText(http.StatusOK),
+		Body:       io.NopCloser(bytes.NewBufferString(reply)),
+	}, nil
+}
+
+func TestOperationTimeouts(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []gobayeux.Option
+		want map[gobayeux.Channel]time.Duration
+	}{
+		{
+			name: "no timeouts",
+			want: map[gobayeux.Channel]time.Duration{},
+		},
+		{
+			name: "meta and connect timeouts",
+			opts: []gobayeux.Option{
+				gobayeux.WithMetaTimeout(2 * time.Second),
+				gobayeux.WithConnectTimeout(5 * time.Second),
+			},
+			want: map[gobayeux.Channel]time.Duration{
+				gobayeux.MetaHandshake: 2 * time.Second,
+				gobayeux.MetaSubscribe: 2 * time.Second,
+				// The advised 20s timeout plus the allowance
+				gobayeux.MetaConnect: 25 * time.Second,
+			},
+		},
+		{
+			name: "handshake timeout overrides meta timeout",
+			opts: []gobayeux.Option{
+				gobayeux.WithMetaTimeout(2 * time.Second),
+				gobayeux.WithHandshakeTimeout(4 * time.Second),
+			},
+			want: map[gobayeux.Channel]time.Duration{
+				gobayeux.MetaHandshake: 4 * time.Second,
+				gobayeux.MetaSubscribe: 2 * time.Second,
+			},
+		},
+		{
+			name: "meta timeout leaves connect alone",
+			opts: []gobayeux.Option{gobayeux.WithMetaTimeout(2 * time.Second)},
+			want: map[gobayeux.Channel]time.Duration{
+				gobayeux.MetaHandshake: 2 * time.Second,
+				gobayeux.MetaSubscribe: 2 * time.Second,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			server := &deadlineServer{remaining: make(map[gobayeux.Channel]time.Duration)}
+			client, err := gobayeux.NewBayeuxClient(nil, server, "https://example.com", nil, tc.opts...)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			ctx := context.Background()
+			if _, err := client.Handshake(ctx); err != nil {
+				t.Fatalf("failed to handshake (%v)", err)
+			}
+			if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar"}); err != nil {
+				t.Fatalf("failed to subscribe (%v)", err)
+			}
+			if _, err := client.Connect(ctx); err != nil {
+				t.Fatalf("failed to connect (%v)", err)
+			}
+
+			if len(server.remaining) != len(tc.want) {
+				t.Errorf("expected deadlines on %v, got %v", tc.want, server.remaining)
+			}
+			for channel, want := range tc.want {
+				got, ok := server.remaining[channel]
+				if !ok {
+					t.Errorf("expected a deadline on %s", channel)
+					continue
+				}
+				if got > want || got < want-time.Second {
+					t.Errorf("expected about %v left on %s, got %v", want, channel, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWithHandshakeTimeoutOnHungServer(t *testing.T) {
+	// The server accepts the connection but never replies
+	hang := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(hang)
+
+	client, err := gobayeux.NewClient(server.URL, gobayeux.WithHandshakeTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client.Start(ctx)
+
+	err = client.WaitReady(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the handshake to time out, got %v", err)
+	}
+	if ctx.Err() != nil {
+		t.Error("expected the handshake timeout rather than the test's to expire")
+	}
+}
@@ -0,0 +1,28 @@
+package gobayeux
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// WithTLSConfig returns an Option which uses config for connections to the
+// Bayeux server, e.g., to trust a corporate CA or present a client
+// certificate. The transport in use must be an *http.Transport; it is cloned
+// rather than modified.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(options *Options) {
+		options.TLSConfig = config
+	}
+}
+
+// withTLSConfig returns a copy of rt using config
+func withTLSConfig(rt http.RoundTripper, config *tls.Config) (http.RoundTripper, error) {
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, ErrTLSConfigUnsupported
+	}
+
+	transport = transport.Clone()
+	transport.TLSClientConfig = config.Clone()
+	return transport, nil
+}
@@ -0,0 +1,60 @@
+package gobayeux_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestWithTLSConfig(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"channel":"/meta/handshake","clientId":"abc","successful":true}]`))
+	}))
+	// The rejected handshake is expected to log a TLS error on the server
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	t.Run("self-signed certificate is trusted via RootCAs", func(t *testing.T) {
+		client, err := gobayeux.NewBayeuxClient(nil, nil, server.URL, nil,
+			gobayeux.WithTLSConfig(&tls.Config{RootCAs: pool}),
+		)
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		if _, err := client.Handshake(context.Background()); err != nil {
+			t.Fatalf("expected the handshake to succeed, got %v", err)
+		}
+	})
+
+	t.Run("self-signed certificate is rejected by default", func(t *testing.T) {
+		client, err := gobayeux.NewBayeuxClient(nil, nil, server.URL, nil)
+		if err != nil {
+			t.Fatalf("failed to create client (%v)", err)
+		}
+		if _, err := client.Handshake(context.Background()); err == nil {
+			t.Fatal("expected the handshake to fail certificate verification")
+		}
+	})
+
+	t.Run("transport must be an *http.Transport", func(t *testing.T) {
+		_, err := gobayeux.NewBayeuxClient(nil, roundTripFn(http.DefaultTransport.RoundTrip), server.URL, nil,
+			gobayeux.WithTLSConfig(&tls.Config{RootCAs: pool}),
+		)
+		if !errors.Is(err, gobayeux.ErrTLSConfigUnsupported) {
+			t.Errorf("expected ErrTLSConfigUnsupported, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package gobayeux
+
+import "time"
+
+// WithConnectErrorTolerance returns an Option which keeps the Client running
+// through up to n failed /meta/connect requests within window. A tolerated
+// failure is reported on the channel returned by Start and the Client
+// connects again once the advised interval has elapsed, or handshakes again
+// when the server advises it. One more failure within window stops the
+// Client as usual.
+//
+// Failures with a StatusAction configured by WithStatusHandler are handled
+// by it instead. By default any other failure stops the Client.
+func WithConnectErrorTolerance(n int, window time.Duration) Option {
+	return func(options *Options) {
+		options.ConnectErrorTolerance = n
+		options.ConnectErrorWindow = window
+	}
+}
+
+// errorTolerance counts the failures within a sliding window
+type errorTolerance struct {
+	limit    int
+	window   time.Duration
+	failures []time.Time
+}
+
+// newErrorTolerance returns nil, which tolerates nothing, unless limit is
+// positive
+func newErrorTolerance(limit int, window time.Duration) *errorTolerance {
+	if limit <= 0 {
+		return nil
+	}
+	return &errorTolerance{limit: limit, window: window}
+}
+
+// allow records a failure at now and reports whether it is tolerated
+func (t *errorTolerance) allow(now time.Time) bool {
+	if t == nil {
+		return false
+	}
+	recent := t.failures[:0]
+	for _, failure := range t.failures {
+		if now.Sub(failure) < t.window {
+			recent = append(recent, failure)
+		}
+	}
+	t.failures = append(recent, now)
+	return len(t.failures) <= t.limit
+}
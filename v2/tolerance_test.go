@@ -0,0 +1,135 @@
+package gobayeux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestErrorTolerance(t *testing.T) {
+	start := time.Unix(0, 0)
+	tolerance := newErrorTolerance(2, time.Minute)
+	for i, tc := range []struct {
+		at   time.Duration
+		want bool
+	}{
+		{0, true},
+		{10 * time.Second, true},
+		{20 * time.Second, false},
+		// The first two failures have left the window by now
+		{75 * time.Second, true},
+		{76 * time.Second, false},
+	} {
+		if got := tolerance.allow(start.Add(tc.at)); got != tc.want {
+			t.Errorf("failure %d at %s: want %t, got %t", i, tc.at, tc.want, got)
+		}
+	}
+
+	if newErrorTolerance(0, time.Minute).allow(start) {
+		t.Error("expected no failure to be tolerated by default")
+	}
+}
+
+// flakyConnectTransport fails the /meta/connect requests whose number is in
+// failures with a 502
+func flakyConnectTransport(connects *int32, failures map[int32]bool) transportFn {
+	return func(r *http.Request) (*http.Response, error) {
+		var requests []Message
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			return nil, err
+		}
+		var replies []Message
+		for _, m := range requests {
+			reply := Message{Channel: m.Channel, ID: m.ID, ClientID: "abc", Successful: true}
+			if m.Channel == MetaConnect {
+				if failures[atomic.AddInt32(connects, 1)] {
+					return &http.Response{
+						StatusCode: http.StatusBadGateway,
+						Status:     http.StatusText(http.StatusBadGateway),
+						Body:       io.NopCloser(bytes.NewReader(nil)),
+					}, nil
+				}
+				reply.Advice = &Advice{Reconnect: ReconnectRetry, Interval: 1000}
+			}
+			replies = append(replies, reply)
+		}
+		body, err := json.Marshal(replies)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Status:     http.StatusText(http.StatusOK),
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}, nil
+	}
+}
+
+func TestWithConnectErrorTolerance(t *testing.T) {
+	testCases := []struct {
+		name      string
+		failures  map[int32]bool
+		wantFatal bool
+	}{
+		{"recovers within tolerance", map[int32]bool{2: true, 4: true}, false},
+		{"too many failures", map[int32]bool{2: true, 3: true, 4: true}, true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			var connects int32
+			clock := newFakeClock()
+			client, err := NewClient("https://example.com",
+				WithHTTPTransport(flakyConnectTransport(&connects, tc.failures)),
+				WithConnectErrorTolerance(2, time.Minute),
+				withClock(clock),
+			)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errs := client.Start(ctx)
+			client.lifecycleLock.Lock()
+			done := client.pollDone
+			client.lifecycleLock.Unlock()
+
+			reported := 0
+			for atomic.LoadInt32(&connects) < 6 {
+				select {
+				case <-clock.added:
+					clock.Advance(time.Second)
+				case err := <-errs:
+					var badResponse BadResponseError
+					if !errors.As(err, &badResponse) || badResponse.StatusCode != http.StatusBadGateway {
+						t.Fatalf("expected a BadResponseError with status 502, got %v", err)
+					}
+					reported++
+				case <-done:
+					if !tc.wantFatal {
+						t.Fatal("expected the client to keep running")
+					}
+					if reported != 3 {
+						t.Errorf("expected 2 tolerated failures and a fatal one, got %d errors", reported)
+					}
+					return
+				case <-time.After(5 * time.Second):
+					t.Fatal("timed out waiting for the client to connect again")
+				}
+			}
+			if tc.wantFatal {
+				t.Fatal("expected the client to stop")
+			}
+			if reported != 2 {
+				t.Errorf("expected 2 tolerated failures to be reported, got %d", reported)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package gobayeux
+
+import (
+	"net/http"
+	"time"
+)
+
+// WithMaxIdleConns returns an Option which keeps up to n idle connections
+// to the Bayeux server open for reuse. The transport in use must be an
+// *http.Transport; it is cloned rather than modified.
+func WithMaxIdleConns(n int) Option {
+	return func(options *Options) {
+		options.MaxIdleConns = n
+	}
+}
+
+// WithIdleConnTimeout returns an Option which closes connections that have
+// been idle for d. A connection holding a /meta/connect is in use rather
+// than idle, so no timeout set here cuts off a long poll. The transport in
+// use must be an *http.Transport; it is cloned rather than modified.
+func WithIdleConnTimeout(d time.Duration) Option {
+	return func(options *Options) {
+		options.IdleConnTimeout = d
+	}
+}
+
+// WithForceHTTP2 returns an Option which attempts HTTP/2 even when the
+// transport has a custom TLS configuration or dialer, which otherwise turn
+// it off. Over HTTP/2 a long poll and the requests made alongside it share
+// one connection. The transport in use must be an *http.Transport; it is
+// cloned rather than modified.
+func WithForceHTTP2() Option {
+	return func(options *Options) {
+		options.ForceHTTP2 = true
+	}
+}
+
+// tunesTransport reports whether any option configuring the transport's
+// connections was given
+func (options *Options) tunesTransport() bool {
+	return options.MaxIdleConns > 0 || options.IdleConnTimeout > 0 || options.ForceHTTP2
+}
+
+// withTransportTuning returns a copy of rt configured by options
+func withTransportTuning(rt http.RoundTripper, options *Options) (http.RoundTripper, error) {
+	transport, ok := rt.(*http.Transport)
+	if !ok {
+		return nil, ErrTransportTuningUnsupported
+	}
+
+	transport = transport.Clone()
+	if options.MaxIdleConns > 0 {
+		// Every request goes to the same host
+		transport.MaxIdleConns = options.MaxIdleConns
+		transport.MaxIdleConnsPerHost = options.MaxIdleConns
+	}
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+	if options.ForceHTTP2 {
+		transport.ForceAttemptHTTP2 = true
+	}
+	return transport, nil
+}
@@ -0,0 +1,140 @@
+package gobayeux_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+)
+
+// metaHandler replies successfully to every message, holding each
+// /meta/connect for hold
+func metaHandler(t *testing.T, hold time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var ms []gobayeux.Message
+		if err := json.NewDecoder(r.Body).Decode(&ms); err != nil {
+			t.Errorf("failed to decode request (%v)", err)
+			return
+		}
+		replies := make([]gobayeux.Message, 0, len(ms))
+		for _, m := range ms {
+			if m.Channel == gobayeux.MetaConnect {
+				time.Sleep(hold)
+			}
+			replies = append(replies, gobayeux.Message{
+				Channel:                  m.Channel,
+				ID:                       m.ID,
+				ClientID:                 "abc",
+				Successful:               true,
+				Subscription:             m.Subscription,
+				SupportedConnectionTypes: []string{gobayeux.ConnectionTypeLongPolling},
+				Version:                  "1.0",
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(replies)
+	}
+}
+
+func TestWithIdleConnTimeout(t *testing.T) {
+	idleTimeout := 50 * time.Millisecond
+	server := httptest.NewUnstartedServer(metaHandler(t, 4*idleTimeout))
+	var conns int32
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client, err := gobayeux.NewBayeuxClient(nil, nil, server.URL, nil,
+		gobayeux.WithIdleConnTimeout(idleTimeout),
+		gobayeux.WithMaxIdleConns(4),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx := context.Background()
+	if _, err := client.Handshake(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+	// The long poll is held for longer than a connection may be idle
+	if _, err := client.Connect(ctx); err != nil {
+		t.Fatalf("expected the long poll to survive the idle timeout, got %v", err)
+	}
+	if _, err := client.Subscribe(ctx, []gobayeux.Channel{"/foo/bar"}); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	if got := atomic.LoadInt32(&conns); got != 1 {
+		t.Errorf("expected the connection held by the long poll to be reused, got %d connections", got)
+	}
+
+	// Once idle for longer than the timeout the connection is closed
+	time.Sleep(4 * idleTimeout)
+	if _, err := client.Unsubscribe(ctx, []gobayeux.Channel{"/foo/bar"}); err != nil {
+		t.Fatalf("failed to unsubscribe (%v)", err)
+	}
+	if got := atomic.LoadInt32(&conns); got != 2 {
+		t.Errorf("expected the idle connection to be replaced, got %d connections", got)
+	}
+}
+
+func TestWithForceHTTP2(t *testing.T) {
+	var proto int32
+	handler := metaHandler(t, 0)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.StoreInt32(&proto, int32(r.ProtoMajor))
+		handler(w, r)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	testCases := []struct {
+		name  string
+		opts  []gobayeux.Option
+		proto int32
+	}{
+		{"custom TLS configuration turns off HTTP/2", nil, 1},
+		{"forced", []gobayeux.Option{gobayeux.WithForceHTTP2()}, 2},
+	}
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]gobayeux.Option{gobayeux.WithTLSConfig(&tls.Config{RootCAs: pool})}, tc.opts...)
+			client, err := gobayeux.NewBayeuxClient(nil, &http.Transport{}, server.URL, nil, opts...)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			if _, err := client.Handshake(context.Background()); err != nil {
+				t.Fatalf("failed to handshake (%v)", err)
+			}
+			if got := atomic.LoadInt32(&proto); got != tc.proto {
+				t.Errorf("expected HTTP/%d, got HTTP/%d", tc.proto, got)
+			}
+		})
+	}
+}
+
+func TestTransportTuningRequiresHTTPTransport(t *testing.T) {
+	_, err := gobayeux.NewBayeuxClient(nil, roundTripFn(http.DefaultTransport.RoundTrip), "https://example.com", nil,
+		gobayeux.WithIdleConnTimeout(time.Minute),
+	)
+	if !errors.Is(err, gobayeux.ErrTransportTuningUnsupported) {
+		t.Errorf("expected ErrTransportTuningUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package gobayeux
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Subscribe queues a request to subscribe to ch, like Client.Subscribe, and
+// returns a channel receiving the Data of each message on ch decoded into a
+// T. A message whose Data cannot be decoded into a T is logged and skipped.
+// The returned channel is closed once ch is unsubscribed from, the
+// subscription is rejected or the client is disconnected.
+func Subscribe[T any](c *Client, ch Channel) (<-chan T, error) {
+	msgs := make(chan []Message, 1)
+	done := c.typed.add(ch, msgs)
+	if err := c.Subscribe(ch, msgs); err != nil {
+		c.typed.refused(ch, msgs)
+		return nil, err
+	}
+
+	values := make(chan T)
+	logger := c.logger.WithField("at", "subscribe").WithField("channel", ch)
+	deliver := func(batch []Message) bool {
+		for _, m := range batch {
+			var value T
+			if err := json.Unmarshal(m.Data, &value); err != nil {
+				logger.WithError(err).WithField("id", m.ID).Warn("skipping message that cannot be decoded")
+				continue
+			}
+			select {
+			case values <- value:
+			case <-c.shutdown:
+				return false
+			}
+		}
+		return true
+	}
+
+	c.goroutines.Add(1)
+	go func() {
+		defer c.goroutines.Done()
+		defer close(values)
+		for {
+			select {
+			case batch := <-msgs:
+				if !deliver(batch) {
+					return
+				}
+			case <-done:
+				// Nothing is sent on msgs once unsubscribed but what was
+				// already delivered is passed on first
+				for {
+					select {
+					case batch := <-msgs:
+						if !deliver(batch) {
+							return
+						}
+					default:
+						return
+					}
+				}
+			case <-c.shutdown:
+				return
+			}
+		}
+	}()
+	return values, nil
+}
+
+// typedSubscriptions tells the goroutines started by Subscribe when their
+// channel has been unsubscribed from or their subscription was refused
+type typedSubscriptions struct {
+	lock sync.Mutex
+	done map[Channel]map[chan []Message]chan struct{}
+}
+
+// add returns a channel which is closed once ch is unsubscribed from or the
+// subscription receiving on msgs is refused
+func (ts *typedSubscriptions) add(ch Channel, msgs chan []Message) <-chan struct{} {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	if ts.done == nil {
+		ts.done = make(map[Channel]map[chan []Message]chan struct{})
+	}
+	if ts.done[ch] == nil {
+		ts.done[ch] = make(map[chan []Message]chan struct{})
+	}
+	done := make(chan struct{})
+	ts.done[ch][msgs] = done
+	return done
+}
+
+// unsubscribed closes every channel returned by add for ch
+func (ts *typedSubscriptions) unsubscribed(ch Channel) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	for _, done := range ts.done[ch] {
+		close(done)
+	}
+	delete(ts.done, ch)
+}
+
+// refused closes the channel returned by add for the subscription to ch
+// receiving on msgs, leaving any other subscription to ch alone. Receivers
+// that were not added by Subscribe are ignored.
+func (ts *typedSubscriptions) refused(ch Channel, msgs chan []Message) {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	done, ok := ts.done[ch][msgs]
+	if !ok {
+		return
+	}
+	close(done)
+	delete(ts.done[ch], msgs)
+	if len(ts.done[ch]) == 0 {
+		delete(ts.done, ch)
+	}
+}
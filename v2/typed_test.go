@@ -0,0 +1,177 @@
+package gobayeux_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/gobayeuxtest"
+)
+
+type order struct {
+	ID   int    `json:"id"`
+	Item string `json:"item"`
+}
+
+func TestSubscribeTyped(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 50})
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	orders, err := gobayeux.Subscribe[order](client, "/orders")
+	if err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	clientID := client.SessionInfo().ClientID
+	for len(server.Subscriptions(clientID)) == 0 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the subscription")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	server.Publish("/orders", json.RawMessage(`{"id":1,"item":"book"}`))
+	// Data that is not an order is skipped
+	server.Publish("/orders", json.RawMessage(`"book"`))
+	server.Publish("/orders", json.RawMessage(`{"id":2,"item":"pen"}`))
+
+	for _, want := range []order{{1, "book"}, {2, "pen"}} {
+		select {
+		case got := <-orders:
+			if got != want {
+				t.Errorf("want %+v, got %+v", want, got)
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for %+v", want)
+		}
+	}
+
+	if err := client.Unsubscribe("/orders"); err != nil {
+		t.Fatalf("failed to unsubscribe (%v)", err)
+	}
+	select {
+	case got, ok := <-orders:
+		if ok {
+			t.Errorf("expected the channel to be closed, got %+v", got)
+		}
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the channel to be closed")
+	}
+}
+
+func TestSubscribeTypedClosesWhenRejected(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 50})
+	server.Deny("/orders")
+
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithContinueOnError(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	orders, err := gobayeux.Subscribe[order](client, "/orders")
+	if err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	for {
+		select {
+		case got, ok := <-orders:
+			if ok {
+				t.Fatalf("expected the channel to be closed, got %+v", got)
+			}
+			return
+		case err := <-errs:
+			var subErr gobayeux.SubscriptionFailedError
+			if !errors.As(err, &subErr) {
+				t.Fatalf("expected a SubscriptionFailedError, got %v", err)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the channel to be closed")
+		}
+	}
+}
+
+func TestSubscribeTypedClosesWhenAlreadySubscribed(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	server.SetGenerateEvents(false)
+	server.SetAdvice(gobayeux.Advice{Reconnect: "retry", Timeout: 50})
+
+	client, err := gobayeux.NewClient("https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithContinueOnError(true),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	if err := client.WaitReady(ctx); err != nil {
+		t.Fatalf("failed to handshake (%v)", err)
+	}
+
+	// Without fan-out the channel already has its receiver
+	msgs := make(chan []gobayeux.Message, 1)
+	if err := client.Subscribe("/orders", msgs); err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	orders, err := gobayeux.Subscribe[order](client, "/orders")
+	if err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	for {
+		select {
+		case got, ok := <-orders:
+			if ok {
+				t.Fatalf("expected the channel to be closed, got %+v", got)
+			}
+			return
+		case err := <-errs:
+			if !errors.Is(err, gobayeux.ErrAlreadySubscribed) {
+				t.Fatalf("expected ErrAlreadySubscribed, got %v", err)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out waiting for the channel to be closed")
+		}
+	}
+}